@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/k1networth/servicedesk-lite/internal/shared/blob"
+	"github.com/k1networth/servicedesk-lite/internal/shared/events"
+)
+
+// errNoBlobStore: the message has a PayloadRef but CLAIM_CHECK_DIR is not configured.
+var errNoBlobStore = errors.New("payload is offloaded but CLAIM_CHECK_DIR is not set")
+
+// loadPayload resolves a claim check: if the envelope carries a PayloadRef, the payload is
+// fetched from blobs and verified against the reference's size and SHA-256, so the rest of
+// the handler sees an ordinary inline payload. Envelopes with an inline payload are
+// returned unchanged.
+func loadPayload(ctx context.Context, blobs blob.Store, env events.Envelope) (events.Envelope, error) {
+	if env.PayloadRef == nil {
+		return env, nil
+	}
+	if blobs == nil {
+		return env, errNoBlobStore
+	}
+	data, err := blob.Load(ctx, blobs, *env.PayloadRef)
+	if err != nil {
+		return env, err
+	}
+	env.Payload = data
+	env.PayloadRef = nil
+	return env, nil
+}
+
+// claimCheckPermanent reports whether a loadPayload error fails every retry: the blob is
+// missing or corrupt, or there is no store to read it from. Only I/O errors are worth retrying.
+func claimCheckPermanent(err error) bool {
+	return errors.Is(err, blob.ErrChecksum) || errors.Is(err, blob.ErrNotFound) || errors.Is(err, errNoBlobStore)
+}
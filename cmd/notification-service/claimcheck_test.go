@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/k1networth/servicedesk-lite/internal/shared/blob"
+	"github.com/k1networth/servicedesk-lite/internal/shared/events"
+)
+
+func offloadedMessage(t *testing.T, ref events.PayloadRef) []byte {
+	t.Helper()
+	b, err := json.Marshal(events.Envelope{
+		EventID:       "e-1",
+		EventType:     events.EventTypeTicketCreated,
+		SchemaVersion: 2,
+		OccurredAt:    time.Now().UTC(),
+		Aggregate:     "ticket",
+		AggregateID:   "t-1",
+		Payload:       json.RawMessage("null"),
+		PayloadRef:    &ref,
+	})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return b
+}
+
+// The failures below happen before the store is used, hence the nil *notify.Store.
+func handleOffloaded(blobs blob.Store, value []byte) error {
+	var evType string
+	var attempt int
+	noHeaders := func(string) (string, bool) { return "", false }
+	return handleMessage(context.Background(), slog.New(slog.NewTextHandler(io.Discard, nil)), nil, blobs,
+		value, noHeaders, false, "", &evType, &attempt)
+}
+
+func TestHandleMessageSendsCorruptClaimCheckToDLQ(t *testing.T) {
+	blobs, err := blob.NewFSStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("blob store: %v", err)
+	}
+	ref, err := blob.Offload(context.Background(), blobs, []byte(`{"ticket_id":"t-1"}`))
+	if err != nil {
+		t.Fatalf("offload: %v", err)
+	}
+
+	corrupt := ref
+	corrupt.SHA256 = strings.Repeat("0", 64)
+	missing := ref
+	missing.URI = "fs:" + strings.Repeat("1", 64)
+
+	cases := map[string]struct {
+		blobs blob.Store
+		ref   events.PayloadRef
+	}{
+		"checksum mismatch": {blobs, corrupt},
+		"missing blob":      {blobs, missing},
+		"no blob store":     {nil, ref},
+	}
+	for name, c := range cases {
+		err := handleOffloaded(c.blobs, offloadedMessage(t, c.ref))
+		if err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+		if classify(err) != "claim_check" || !nonRetryable(err) {
+			t.Fatalf("%s: expected a non-retryable claim_check error, got %v", name, err)
+		}
+	}
+}
+
+func TestClaimCheckIOErrorsAreRetryable(t *testing.T) {
+	if nonRetryable(wrap("claim_check", io.ErrUnexpectedEOF)) {
+		t.Fatalf("I/O errors while loading a blob must be retried")
+	}
+}
@@ -13,15 +13,19 @@ import (
 	"time"
 
 	"github.com/k1networth/servicedesk-lite/internal/notify"
+	"github.com/k1networth/servicedesk-lite/internal/retention"
+	"github.com/k1networth/servicedesk-lite/internal/shared/blob"
 	"github.com/k1networth/servicedesk-lite/internal/shared/config"
 	"github.com/k1networth/servicedesk-lite/internal/shared/db"
 	"github.com/k1networth/servicedesk-lite/internal/shared/env"
 	"github.com/k1networth/servicedesk-lite/internal/shared/events"
 	"github.com/k1networth/servicedesk-lite/internal/shared/kafkax"
 	"github.com/k1networth/servicedesk-lite/internal/shared/logger"
+	"github.com/k1networth/servicedesk-lite/internal/shared/retry"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/segmentio/kafka-go"
 )
 
 const appName = "notification-service"
@@ -42,9 +46,40 @@ func main() {
 	startOffset := env.String("KAFKA_START_OFFSET", "last")
 	dlqTopic := env.String("KAFKA_DLQ_TOPIC", "")
 	maxAttempts := env.Int("NOTIFY_MAX_ATTEMPTS", 10)
+	retrySpec := env.String("NOTIFY_RETRY_POLICY", "exponential:base=200ms,max=30s,jitter=0.5")
+	retryByType := env.String("NOTIFY_RETRY_POLICY_BY_TYPE", "")
+	eventTypesList := env.StringsCSV("NOTIFY_EVENT_TYPES", nil)
+	eventTypes := map[string]bool{}
+	for _, t := range eventTypesList {
+		eventTypes[t] = true
+	}
 	forceFail := env.Bool("NOTIFY_FORCE_FAIL", false)
 	forceFailEventType := env.String("NOTIFY_FORCE_FAIL_EVENT_TYPE", "")
+	retentionCfg := retention.Config{
+		MaxAge:     env.Duration("RETENTION_MAX_AGE", 0),
+		Interval:   env.Duration("RETENTION_INTERVAL", 10*time.Minute),
+		BatchSize:  env.Int("RETENTION_BATCH_SIZE", 1000),
+		ArchiveDir: env.String("RETENTION_ARCHIVE_DIR", ""),
+	}
 	metricsAddr := env.String("METRICS_ADDR", ":9091")
+	claimCheckDir := env.String("CLAIM_CHECK_DIR", "")
+
+	retryPolicies, err := retry.ParsePolicies(retrySpec, retryByType)
+	if err != nil {
+		log.Error("config_error", slog.String("err", err.Error()))
+		os.Exit(2)
+	}
+
+	// Offloaded payloads (claim check) are read from the volume ticket-service writes to.
+	var blobs blob.Store
+	if claimCheckDir != "" {
+		fs, err := blob.NewFSStore(claimCheckDir)
+		if err != nil {
+			log.Error("config_error", slog.String("err", err.Error()))
+			os.Exit(2)
+		}
+		blobs = fs
+	}
 
 	// Debug-only: log raw env values to avoid "0 treated as true" style bugs.
 	// IMPORTANT: logic must use parsed values above.
@@ -124,6 +159,12 @@ func main() {
 		}()
 	}
 
+	// Retention: delete (and optionally archive) finished rows; disabled when RETENTION_MAX_AGE=0.
+	if retentionCfg.MaxAge > 0 {
+		job := retention.NewJob(pg, retention.ProcessedEvents, retentionCfg, log, retention.NewMetrics(reg))
+		go job.Run(ctx)
+	}
+
 	log.Info(
 		"consumer_start",
 		slog.String("topic", topic),
@@ -131,10 +172,16 @@ func main() {
 		slog.String("start_offset", startOffset),
 		slog.String("dlq_topic", dlqTopic),
 		slog.Int("max_attempts", maxAttempts),
+		slog.Any("event_types", eventTypesList),
+		slog.String("retry_policy", retrySpec),
+		slog.String("retry_policy_by_type", retryByType),
 		slog.String("max_attempts_raw", maxAttemptsRaw),
 		slog.Bool("force_fail", forceFail),
 		slog.String("force_fail_raw", forceFailRaw),
 		slog.String("force_fail_event_type", forceFailEventType),
+		slog.String("retention_max_age", retentionCfg.MaxAge.String()),
+		slog.String("retention_archive_dir", retentionCfg.ArchiveDir),
+		slog.String("claim_check_dir", claimCheckDir),
 	)
 
 	// If the reader gets stuck in a "no messages" state due to stale metadata / group assignment glitches,
@@ -145,6 +192,17 @@ func main() {
 	timeoutStreak := 0
 	lastReopenAt := time.Time{}
 
+	// skip commits a message whose event type is not in NOTIFY_EVENT_TYPES, unprocessed.
+	skip := func(msg kafka.Message, evType string) {
+		if err := consumer.CommitMessages(ctx, msg); err != nil {
+			log.Error("kafka_commit_failed", slog.String("err", err.Error()))
+		} else {
+			committed.Inc()
+		}
+		processed.WithLabelValues(evType, "skipped").Inc()
+		log.Debug("kafka_message_skipped", slog.String("event_type", evType), slog.Int64("offset", msg.Offset))
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -185,10 +243,31 @@ func main() {
 			timeoutStreak = 0
 
 			fetched.Inc()
+
+			// Route by headers first: skipping an unwanted event type needs no JSON decoding.
+			// Messages without headers (older relays) fall through to the envelope.
+			// Binary CloudEvents from other producers carry the type in ce_type only.
+			header := func(key string) (string, bool) { return kafkax.HeaderValue(msg, key) }
+			hdrType, hasType := header(events.HeaderEventType)
+			if !hasType {
+				hdrType, hasType = header(events.CEHeaderType)
+			}
+			if hasType && !allowedType(eventTypes, hdrType) {
+				skip(msg, hdrType)
+				continue
+			}
+
 			// Log fetch with best-effort envelope info (helps prove Kafka -> consumer in demos).
-			{
-				var env events.Envelope
-				if err := json.Unmarshal(msg.Value, &env); err == nil {
+			if hdrID, ok := kafkax.HeaderValue(msg, events.HeaderEventID); ok && hasType {
+				log.Info("kafka_message_fetched",
+					slog.Int("partition", msg.Partition),
+					slog.Int64("offset", msg.Offset),
+					slog.String("event_id", hdrID),
+					slog.String("event_type", hdrType),
+					slog.String("aggregate_id", string(msg.Key)),
+				)
+			} else {
+				if env, err := events.Decode(msg.Value, header); err == nil {
 					log.Info("kafka_message_fetched",
 						slog.Int("partition", msg.Partition),
 						slog.Int64("offset", msg.Offset),
@@ -196,6 +275,11 @@ func main() {
 						slog.String("event_type", env.EventType),
 						slog.String("aggregate_id", env.AggregateID),
 					)
+					// No type header: the allow-list applies to the envelope's event_type.
+					if !hasType && !allowedType(eventTypes, env.EventType) {
+						skip(msg, env.EventType)
+						continue
+					}
 				} else {
 					log.Warn("kafka_message_fetched_unmarshal_failed",
 						slog.Int("partition", msg.Partition),
@@ -206,14 +290,20 @@ func main() {
 			}
 
 			evType := "unknown"
+			if hasType {
+				evType = hdrType
+			}
 			attempt := 0
+			tries := 0              // local count: failures before StartProcessing leave attempt at 0
+			var delay time.Duration // previous retry delay, for decorrelated policies
 
 			// Important: kafka-go Reader (with GroupID) will continue fetching newer messages even if
 			// we don't commit. So "retry by not committing" does NOT re-deliver the same message.
 			// To implement retries deterministically, we retry handling the SAME message in-process
 			// and commit only after success or after moving it to failed/DLQ.
 			for {
-				err = handleMessage(ctx, log, store, msg.Value, forceFail, forceFailEventType, &evType, &attempt)
+				tries++
+				err = handleMessage(ctx, log, store, blobs, msg.Value, header, forceFail, forceFailEventType, &evType, &attempt)
 				if err == nil {
 					processed.WithLabelValues(evType, "ok").Inc()
 					if err := consumer.CommitMessages(ctx, msg); err != nil {
@@ -229,14 +319,15 @@ func main() {
 					break
 				}
 
+				attempt = max(attempt, tries)
 				reason := classify(err)
 				errTotal.WithLabelValues(evType, reason).Inc()
 				log.Error("message_handle_failed", slog.String("reason", reason), slog.Int("attempt", attempt), slog.String("err", err.Error()))
 
-				// Non-retryable: can't decode the message.
-				if reason == "unmarshal" {
+				// Non-retryable: can't decode the message, load its payload or upcast it.
+				if nonRetryable(err) {
 					if dlqProducer != nil {
-						_ = dlqProducer.Produce(ctx, msg.Key, wrapDLQ(msg.Value, err), 5*time.Second)
+						_ = dlqProducer.ProduceBatch(ctx, []kafkax.Message{dlqMessage(msg, err)}, 5*time.Second)
 					}
 					if err := consumer.CommitMessages(ctx, msg); err != nil {
 						log.Error("kafka_commit_failed", slog.String("err", err.Error()))
@@ -252,9 +343,9 @@ func main() {
 				// No infinite loops: after max attempts, send to DLQ (best-effort), mark failed and commit.
 				if maxAttempts > 0 && attempt >= maxAttempts {
 					if dlqProducer != nil {
-						_ = dlqProducer.Produce(ctx, msg.Key, wrapDLQ(msg.Value, err), 5*time.Second)
+						_ = dlqProducer.ProduceBatch(ctx, []kafkax.Message{dlqMessage(msg, err)}, 5*time.Second)
 					}
-					_ = store.MarkDead(ctx, extractEventID(msg.Value), err.Error())
+					_ = store.MarkDead(ctx, extractEventID(msg.Value, header), err.Error())
 					if err := consumer.CommitMessages(ctx, msg); err != nil {
 						log.Error("kafka_commit_failed", slog.String("err", err.Error()))
 					} else {
@@ -267,10 +358,9 @@ func main() {
 				}
 
 				// Backoff to avoid busy-loop while retrying same message.
-				// Use select so shutdown (context cancellation) is handled promptly.
-				select {
-				case <-time.After(backoff(attempt)):
-				case <-ctx.Done():
+				// retry.Sleep returns as soon as shutdown (context cancellation) starts.
+				delay = retryPolicies.For(evType).Delay(attempt, delay)
+				if err := retry.Sleep(ctx, retry.SystemClock, delay); err != nil {
 					return
 				}
 			}
@@ -278,11 +368,20 @@ func main() {
 	}
 }
 
-func handleMessage(ctx context.Context, log *slog.Logger, store *notify.Store, value []byte, forceFail bool, forceFailEventType string, eventTypeOut *string, attemptOut *int) error {
-	var env events.Envelope
-	if err := json.Unmarshal(value, &env); err != nil {
+// handleMessage accepts the legacy envelope and CloudEvents (structured or binary), see events.Decode.
+func handleMessage(ctx context.Context, log *slog.Logger, store *notify.Store, blobs blob.Store, value []byte, header func(string) (string, bool), forceFail bool, forceFailEventType string, eventTypeOut *string, attemptOut *int) error {
+	env, err := events.Decode(value, header)
+	if err != nil {
 		return wrap("unmarshal", err)
 	}
+	// A storage hiccup is retried; a missing or corrupt blob goes straight to the DLQ (nonRetryable).
+	if env, err = loadPayload(ctx, blobs, env); err != nil {
+		return wrap("claim_check", err)
+	}
+	// Older payload versions are converted to the current one before anything reads them.
+	if env, err = env.Upcast(); err != nil {
+		return wrap("schema", err)
+	}
 	*eventTypeOut = env.EventType
 
 	// Robust match (avoid invisible whitespace / case issues in demo env vars).
@@ -347,9 +446,24 @@ func classify(err error) string {
 	return "unknown"
 }
 
-func extractEventID(value []byte) string {
-	var env events.Envelope
-	_ = json.Unmarshal(value, &env)
+// nonRetryable errors fail the same way on every attempt, so the message goes to the DLQ at once.
+func nonRetryable(err error) bool {
+	switch classify(err) {
+	case "unmarshal", "schema":
+		return true
+	case "claim_check":
+		return claimCheckPermanent(err)
+	}
+	return false
+}
+
+// allowedType reports whether evType passes NOTIFY_EVENT_TYPES (empty: everything passes).
+func allowedType(eventTypes map[string]bool, evType string) bool {
+	return len(eventTypes) == 0 || eventTypes[evType]
+}
+
+func extractEventID(value []byte, header func(string) (string, bool)) string {
+	env, _ := events.Decode(value, header)
 	return env.EventID
 }
 
@@ -365,14 +479,9 @@ func wrapDLQ(value []byte, err error) []byte {
 	return b
 }
 
-func backoff(attempt int) time.Duration {
-	if attempt <= 1 {
-		return 200 * time.Millisecond
-	}
-	d := time.Duration(1<<uint(min(attempt-1, 6))) * time.Second
-	if d > 30*time.Second {
-		d = 30 * time.Second
-	}
-	return d
+// dlqMessage wraps msg for the DLQ, keeping its key and headers (ce_*, traceparent, ...)
+// so the dead event can still be identified and traced without decoding the body.
+func dlqMessage(msg kafka.Message, err error) kafkax.Message {
+	return kafkax.Message{Key: msg.Key, Value: wrapDLQ(msg.Value, err), Headers: msg.Headers}
 }
 
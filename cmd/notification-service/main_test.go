@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/k1networth/servicedesk-lite/internal/shared/events"
+)
+
+func TestAllowedType(t *testing.T) {
+	only := map[string]bool{events.EventTypeTicketCreated: true}
+	cases := []struct {
+		name       string
+		eventTypes map[string]bool
+		evType     string
+		want       bool
+	}{
+		{"no allow-list", nil, "ticket.closed", true},
+		{"listed", only, events.EventTypeTicketCreated, true},
+		{"not listed", only, "ticket.closed", false},
+		{"empty type from an envelope", only, "", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := allowedType(tc.eventTypes, tc.evType); got != tc.want {
+				t.Fatalf("allowedType(%q) = %v, want %v", tc.evType, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDLQMessageKeepsKeyAndHeaders(t *testing.T) {
+	msg := kafka.Message{
+		Key:   []byte("t-1"),
+		Value: []byte(`{"id":"t-1"}`),
+		Headers: []kafka.Header{
+			{Key: events.CEHeaderType, Value: []byte(events.EventTypeTicketCreated)},
+			{Key: "traceparent", Value: []byte("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")},
+		},
+	}
+
+	got := dlqMessage(msg, errors.New("schema: unsupported version"))
+	if string(got.Key) != "t-1" || !slices.EqualFunc(got.Headers, msg.Headers, func(a, b kafka.Header) bool {
+		return a.Key == b.Key && string(a.Value) == string(b.Value)
+	}) {
+		t.Fatalf("DLQ message lost key or headers: key=%q headers=%v", got.Key, got.Headers)
+	}
+	var body struct {
+		Error string          `json:"error"`
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(got.Value, &body); err != nil || body.Error != "schema: unsupported version" || string(body.Value) != `{"id":"t-1"}` {
+		t.Fatalf("unexpected DLQ body %s (%v)", got.Value, err)
+	}
+}
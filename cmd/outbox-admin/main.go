@@ -0,0 +1,158 @@
+// Command outbox-admin inspects and fixes outbox rows directly in the database, the
+// same operations as outbox-relay's /admin/outbox API (see outbox.Admin). Changes are
+// recorded in outbox_admin_audit.
+//
+//	outbox-admin list    [-status failed] [-type T] [-aggregate A] [-aggregate-id ID] [-limit N] [-json]
+//	outbox-admin counts
+//	outbox-admin requeue|skip|purge [-id 1,2] [-type T] [-aggregate A] [-aggregate-id ID] [-status S] [-all] -reason R
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/k1networth/servicedesk-lite/internal/outbox"
+	"github.com/k1networth/servicedesk-lite/internal/shared/config"
+	"github.com/k1networth/servicedesk-lite/internal/shared/db"
+	"github.com/k1networth/servicedesk-lite/internal/shared/env"
+)
+
+const usage = `usage: outbox-admin <command> [flags]
+
+commands:
+  list     show outbox rows, newest first
+  counts   show the number of rows per status
+  requeue  return failed rows to pending with a fresh attempt budget
+  skip     mark failed or pending rows as cancelled (never published)
+  purge    delete failed, cancelled or sent rows
+
+run "outbox-admin <command> -h" for the flags of a command
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	cmd := os.Args[1]
+
+	var f outbox.AdminFilter
+	var ids, reason, actor string
+	var asJSON bool
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	fs.StringVar(&f.Status, "status", "", "filter by status")
+	fs.StringVar(&f.EventType, "type", "", "filter by event_type")
+	fs.StringVar(&f.Aggregate, "aggregate", "", "filter by aggregate")
+	fs.StringVar(&f.AggregateID, "aggregate-id", "", "filter by aggregate_id")
+	fs.StringVar(&ids, "id", "", "comma-separated outbox ids")
+	switch cmd {
+	case "list":
+		fs.IntVar(&f.Limit, "limit", 100, "max rows to show (up to 1000)")
+		fs.BoolVar(&asJSON, "json", false, "print rows as JSON, with payloads")
+	case "counts":
+	case outbox.AdminRequeue, outbox.AdminSkip, outbox.AdminPurge:
+		fs.BoolVar(&f.All, "all", false, "allow a change without id/type/aggregate filters")
+		fs.StringVar(&reason, "reason", "", "why, recorded in the audit")
+		fs.StringVar(&actor, "actor", os.Getenv("USER"), "who, recorded in the audit")
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	_ = fs.Parse(os.Args[2:])
+
+	for _, s := range strings.Split(ids, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid id %q\n", s)
+			os.Exit(2)
+		}
+		f.IDs = append(f.IDs, id)
+	}
+
+	cfg := config.Load()
+	dbURL := env.String("DATABASE_URL", cfg.DatabaseURL)
+	if dbURL == "" {
+		fmt.Fprintln(os.Stderr, "DATABASE_URL is empty")
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	pg, err := db.OpenPostgres(ctx, db.PostgresConfig{DatabaseURL: dbURL})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "db:", err)
+		os.Exit(1)
+	}
+	defer func() { _ = pg.Close() }()
+
+	if err := run(ctx, outbox.NewAdmin(pg), cmd, f, outbox.AdminAction{Actor: actor, Reason: reason}, asJSON); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, admin *outbox.Admin, cmd string, f outbox.AdminFilter, act outbox.AdminAction, asJSON bool) error {
+	switch cmd {
+	case "list":
+		rows, err := admin.List(ctx, f)
+		if err != nil {
+			return err
+		}
+		if asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(rows)
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tSTATUS\tEVENT_TYPE\tAGGREGATE\tATTEMPTS\tCREATED_AT\tLAST_ERROR")
+		for _, r := range rows {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s/%s\t%d\t%s\t%s\n",
+				r.ID, r.Status, r.EventType, r.Aggregate, r.AggregateID, r.Attempts, r.CreatedAt.Format(time.RFC3339), r.LastError)
+		}
+		return tw.Flush()
+
+	case "counts":
+		counts, err := admin.Counts(ctx)
+		if err != nil {
+			return err
+		}
+		statuses := make([]string, 0, len(counts))
+		for s := range counts {
+			statuses = append(statuses, s)
+		}
+		slices.Sort(statuses)
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "STATUS\tCOUNT")
+		for _, s := range statuses {
+			fmt.Fprintf(tw, "%s\t%d\n", s, counts[s])
+		}
+		return tw.Flush()
+
+	default:
+		change := map[string]func(context.Context, outbox.AdminFilter, outbox.AdminAction) (int64, error){
+			outbox.AdminRequeue: admin.Requeue,
+			outbox.AdminSkip:    admin.Skip,
+			outbox.AdminPurge:   admin.Purge,
+		}[cmd]
+		n, err := change(ctx, f, act)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s: %d event(s)\n", cmd, n)
+		return nil
+	}
+}
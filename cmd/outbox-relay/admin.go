@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/k1networth/servicedesk-lite/internal/outbox"
+)
+
+// adminStore is the part of *outbox.Admin used by the admin API (faked in tests).
+type adminStore interface {
+	List(ctx context.Context, f outbox.AdminFilter) ([]outbox.AdminRow, error)
+	Counts(ctx context.Context) (map[string]int64, error)
+	Requeue(ctx context.Context, f outbox.AdminFilter, act outbox.AdminAction) (int64, error)
+	Skip(ctx context.Context, f outbox.AdminFilter, act outbox.AdminAction) (int64, error)
+	Purge(ctx context.Context, f outbox.AdminFilter, act outbox.AdminAction) (int64, error)
+}
+
+// adminChangeRequest is the body of POST /admin/outbox/{requeue,skip,purge}.
+type adminChangeRequest struct {
+	outbox.AdminFilter
+	Reason string `json:"reason,omitempty"`
+}
+
+// newAdminHandler serves the outbox admin API under /admin/outbox/. Every request needs
+// "Authorization: Bearer <token>"; changes also need X-Admin-Actor, recorded in the audit.
+//
+//	GET  /admin/outbox/events?status=&event_type=&aggregate=&aggregate_id=&id=&limit=
+//	GET  /admin/outbox/counts
+//	POST /admin/outbox/requeue|skip|purge  {"ids": [...], "event_type": "...", ..., "reason": "..."}
+func newAdminHandler(log *slog.Logger, admin adminStore, token string) http.Handler {
+	changes := map[string]func(context.Context, outbox.AdminFilter, outbox.AdminAction) (int64, error){
+		outbox.AdminRequeue: admin.Requeue,
+		outbox.AdminSkip:    admin.Skip,
+		outbox.AdminPurge:   admin.Purge,
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			writeAdminError(w, http.StatusUnauthorized, "unauthorized", "missing or invalid admin token")
+			return
+		}
+
+		op := strings.TrimPrefix(r.URL.Path, "/admin/outbox/")
+		switch op {
+		case "events":
+			if r.Method != http.MethodGet {
+				writeAdminError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+				return
+			}
+			f, err := adminFilterFromQuery(r)
+			if err != nil {
+				writeAdminError(w, http.StatusBadRequest, "validation_error", err.Error())
+				return
+			}
+			rows, err := admin.List(r.Context(), f)
+			if err != nil {
+				log.Error("admin_list_failed", slog.String("err", err.Error()))
+				writeAdminError(w, http.StatusInternalServerError, "internal_error", "internal error")
+				return
+			}
+			writeAdminJSON(w, http.StatusOK, map[string]any{"events": rows})
+
+		case "counts":
+			if r.Method != http.MethodGet {
+				writeAdminError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+				return
+			}
+			counts, err := admin.Counts(r.Context())
+			if err != nil {
+				log.Error("admin_counts_failed", slog.String("err", err.Error()))
+				writeAdminError(w, http.StatusInternalServerError, "internal_error", "internal error")
+				return
+			}
+			writeAdminJSON(w, http.StatusOK, map[string]any{"counts": counts})
+
+		default:
+			change, ok := changes[op]
+			if !ok {
+				writeAdminError(w, http.StatusNotFound, "not_found", "not found")
+				return
+			}
+			if r.Method != http.MethodPost {
+				writeAdminError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+				return
+			}
+			var req adminChangeRequest
+			dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(&req); err != nil {
+				writeAdminError(w, http.StatusBadRequest, "validation_error", "invalid json")
+				return
+			}
+			act := outbox.AdminAction{Actor: r.Header.Get("X-Admin-Actor"), Reason: req.Reason}
+			n, err := change(r.Context(), req.AdminFilter, act)
+			if errors.Is(err, outbox.ErrInvalidRequest) {
+				writeAdminError(w, http.StatusBadRequest, "validation_error", err.Error())
+				return
+			}
+			if err != nil {
+				log.Error("admin_change_failed", slog.String("action", op), slog.String("err", err.Error()))
+				writeAdminError(w, http.StatusInternalServerError, "internal_error", "internal error")
+				return
+			}
+			log.Info("admin_change",
+				slog.String("action", op),
+				slog.String("actor", act.Actor),
+				slog.String("reason", act.Reason),
+				slog.Int64("affected", n),
+			)
+			writeAdminJSON(w, http.StatusOK, map[string]any{"affected": n})
+		}
+	})
+}
+
+func adminFilterFromQuery(r *http.Request) (outbox.AdminFilter, error) {
+	q := r.URL.Query()
+	f := outbox.AdminFilter{
+		Status:      q.Get("status"),
+		EventType:   q.Get("event_type"),
+		Aggregate:   q.Get("aggregate"),
+		AggregateID: q.Get("aggregate_id"),
+	}
+	for _, s := range q["id"] {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return f, errors.New("id must be an integer")
+		}
+		f.IDs = append(f.IDs, id)
+	}
+	if s := q.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return f, errors.New("limit must be a non-negative integer")
+		}
+		f.Limit = n
+	}
+	return f, nil
+}
+
+func writeAdminJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeAdminError(w http.ResponseWriter, status int, code, message string) {
+	writeAdminJSON(w, status, map[string]any{"error": map[string]string{"code": code, "message": message}})
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/k1networth/servicedesk-lite/internal/outbox"
+)
+
+// fakeAdmin records the last request instead of touching a database.
+type fakeAdmin struct {
+	filter outbox.AdminFilter
+	action string
+	act    outbox.AdminAction
+}
+
+func (a *fakeAdmin) List(_ context.Context, f outbox.AdminFilter) ([]outbox.AdminRow, error) {
+	a.filter = f
+	return []outbox.AdminRow{{ID: 7, Status: "failed", EventType: "ticket.created", LastError: "sink down"}}, nil
+}
+
+func (a *fakeAdmin) Counts(context.Context) (map[string]int64, error) {
+	return map[string]int64{"sent": 10, "failed": 2}, nil
+}
+
+func (a *fakeAdmin) change(action string, f outbox.AdminFilter, act outbox.AdminAction) (int64, error) {
+	if act.Actor == "" {
+		return 0, fmt.Errorf("%w: actor is required", outbox.ErrInvalidRequest)
+	}
+	a.action, a.filter, a.act = action, f, act
+	return int64(len(f.IDs)), nil
+}
+
+func (a *fakeAdmin) Requeue(_ context.Context, f outbox.AdminFilter, act outbox.AdminAction) (int64, error) {
+	return a.change(outbox.AdminRequeue, f, act)
+}
+
+func (a *fakeAdmin) Skip(_ context.Context, f outbox.AdminFilter, act outbox.AdminAction) (int64, error) {
+	return a.change(outbox.AdminSkip, f, act)
+}
+
+func (a *fakeAdmin) Purge(_ context.Context, f outbox.AdminFilter, act outbox.AdminAction) (int64, error) {
+	return a.change(outbox.AdminPurge, f, act)
+}
+
+func adminRequest(t *testing.T, h http.Handler, method, target, body string, hdr map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	for k, v := range hdr {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAdminRequiresToken(t *testing.T) {
+	h := newAdminHandler(slog.New(slog.NewTextHandler(io.Discard, nil)), &fakeAdmin{}, "secret")
+
+	for _, auth := range []string{"", "Bearer wrong", "secret"} {
+		req := httptest.NewRequest(http.MethodGet, "/admin/outbox/counts", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("auth %q: got %d, want 401", auth, rec.Code)
+		}
+	}
+}
+
+func TestAdminListsEventsByFilter(t *testing.T) {
+	admin := &fakeAdmin{}
+	h := newAdminHandler(slog.New(slog.NewTextHandler(io.Discard, nil)), admin, "secret")
+
+	rec := adminRequest(t, h, http.MethodGet, "/admin/outbox/events?status=failed&event_type=ticket.created&aggregate=ticket&aggregate_id=t-1&id=7&limit=5", "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body)
+	}
+	want := outbox.AdminFilter{IDs: []int64{7}, Status: "failed", EventType: "ticket.created", Aggregate: "ticket", AggregateID: "t-1", Limit: 5}
+	if fmt.Sprint(admin.filter) != fmt.Sprint(want) {
+		t.Fatalf("filter: got %+v, want %+v", admin.filter, want)
+	}
+	var resp struct {
+		Events []outbox.AdminRow `json:"events"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || len(resp.Events) != 1 || resp.Events[0].LastError != "sink down" {
+		t.Fatalf("unexpected response: %+v (%v)", resp, err)
+	}
+
+	if rec := adminRequest(t, h, http.MethodGet, "/admin/outbox/events?id=x", "", nil); rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid id: got %d, want 400", rec.Code)
+	}
+}
+
+func TestAdminRequeuePassesActorAndReason(t *testing.T) {
+	admin := &fakeAdmin{}
+	h := newAdminHandler(slog.New(slog.NewTextHandler(io.Discard, nil)), admin, "secret")
+
+	rec := adminRequest(t, h, http.MethodPost, "/admin/outbox/requeue", `{"ids":[1,2],"reason":"sink fixed"}`,
+		map[string]string{"X-Admin-Actor": "alice"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body)
+	}
+	if admin.action != outbox.AdminRequeue || admin.act.Actor != "alice" || admin.act.Reason != "sink fixed" || len(admin.filter.IDs) != 2 {
+		t.Fatalf("unexpected call: %s %+v %+v", admin.action, admin.filter, admin.act)
+	}
+	if !strings.Contains(rec.Body.String(), `"affected":2`) {
+		t.Fatalf("unexpected body: %s", rec.Body)
+	}
+
+	if rec := adminRequest(t, h, http.MethodPost, "/admin/outbox/purge", `{"ids":[1]}`, nil); rec.Code != http.StatusBadRequest {
+		t.Fatalf("missing actor: got %d, want 400", rec.Code)
+	}
+	if rec := adminRequest(t, h, http.MethodPost, "/admin/outbox/skip", `{"idz":[1]}`, map[string]string{"X-Admin-Actor": "alice"}); rec.Code != http.StatusBadRequest {
+		t.Fatalf("unknown field: got %d, want 400", rec.Code)
+	}
+	if rec := adminRequest(t, h, http.MethodGet, "/admin/outbox/requeue", "", nil); rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET requeue: got %d, want 405", rec.Code)
+	}
+	if rec := adminRequest(t, h, http.MethodPost, "/admin/outbox/drop", `{}`, nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown action: got %d, want 404", rec.Code)
+	}
+}
@@ -2,7 +2,7 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
@@ -11,12 +11,14 @@ import (
 	"time"
 
 	"github.com/k1networth/servicedesk-lite/internal/outbox"
+	"github.com/k1networth/servicedesk-lite/internal/retention"
 	"github.com/k1networth/servicedesk-lite/internal/shared/config"
 	"github.com/k1networth/servicedesk-lite/internal/shared/db"
 	"github.com/k1networth/servicedesk-lite/internal/shared/env"
 	"github.com/k1networth/servicedesk-lite/internal/shared/events"
 	"github.com/k1networth/servicedesk-lite/internal/shared/kafkax"
 	"github.com/k1networth/servicedesk-lite/internal/shared/logger"
+	"github.com/k1networth/servicedesk-lite/internal/shared/retry"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
@@ -36,14 +38,73 @@ func main() {
 
 	brokers := env.StringsCSV("KAFKA_BROKERS", []string{"localhost:9092"})
 	topic := env.String("KAFKA_TOPIC", "tickets.events")
+	routesFile := env.String("OUTBOX_RELAY_ROUTES_FILE", "")
 	clientID := env.String("KAFKA_CLIENT_ID", appName)
+	sink := sinkConfig{
+		Kind:           env.String("OUTBOX_RELAY_SINK", outbox.SinkKafka),
+		Brokers:        brokers,
+		ClientID:       clientID,
+		WebhookURL:     env.String("OUTBOX_RELAY_WEBHOOK_URL", ""),
+		WebhookTimeout: env.Duration("OUTBOX_RELAY_WEBHOOK_TIMEOUT", 5*time.Second),
+		NATSURL:        env.String("OUTBOX_RELAY_NATS_URL", "nats://localhost:4222"),
+		File:           env.String("OUTBOX_RELAY_FILE", "-"),
+	}
 
 	batchSize := env.Int("OUTBOX_RELAY_BATCH_SIZE", 50)
 	pollInterval := env.Duration("OUTBOX_RELAY_POLL_INTERVAL", 500*time.Millisecond)
 	processingTimeout := env.Duration("OUTBOX_RELAY_PROCESSING_TIMEOUT", 30*time.Second)
 	maxAttempts := env.Int("OUTBOX_RELAY_MAX_ATTEMPTS", 10)
+	strictOrder := env.Bool("OUTBOX_RELAY_STRICT_ORDER", false)
+	priorityMaxWait := env.Duration("OUTBOX_RELAY_PRIORITY_MAX_WAIT", 5*time.Minute)
+	eventFormat := env.String("OUTBOX_RELAY_EVENT_FORMAT", events.FormatEnvelope)
 	dlqTopic := env.String("KAFKA_DLQ_TOPIC", "")
+	retrySpec := env.String("OUTBOX_RELAY_RETRY_POLICY", "exponential:base=1s,max=60s,jitter=0.5")
+	retryByType := env.String("OUTBOX_RELAY_RETRY_POLICY_BY_TYPE", "")
+	workers := env.Int("OUTBOX_RELAY_WORKERS", 1)
+	maxInFlight := env.Int("OUTBOX_RELAY_MAX_IN_FLIGHT", 50)
+	drainTimeout := env.Duration("OUTBOX_RELAY_DRAIN_TIMEOUT", 10*time.Second)
+	breakerThreshold := env.Int("OUTBOX_RELAY_BREAKER_THRESHOLD", 5)
+	breakerCooldown := env.Duration("OUTBOX_RELAY_BREAKER_COOLDOWN", 10*time.Second)
+	shards := env.Int("OUTBOX_RELAY_SHARDS", 0)
+	shardHeartbeat := env.Duration("OUTBOX_RELAY_SHARD_HEARTBEAT", 5*time.Second)
+	shardTTL := env.Duration("OUTBOX_RELAY_SHARD_TTL", 15*time.Second)
+	memberID := env.String("OUTBOX_RELAY_MEMBER_ID", outbox.DefaultMemberID())
+	partitionPremake := env.Int("OUTBOX_PARTITION_PREMAKE_DAYS", 7)
+	partitionRetain := env.Duration("OUTBOX_PARTITION_RETAIN", 0)
+	partitionDrop := env.Bool("OUTBOX_PARTITION_DROP", false)
+	retentionCfg := retention.Config{
+		MaxAge:     env.Duration("RETENTION_MAX_AGE", 0),
+		Interval:   env.Duration("RETENTION_INTERVAL", 10*time.Minute),
+		BatchSize:  env.Int("RETENTION_BATCH_SIZE", 1000),
+		ArchiveDir: env.String("RETENTION_ARCHIVE_DIR", ""),
+	}
 	metricsAddr := env.String("METRICS_ADDR", ":9090")
+	listen := env.Bool("OUTBOX_RELAY_LISTEN", true)
+	adminToken := env.String("OUTBOX_RELAY_ADMIN_TOKEN", "")
+
+	if shards < 0 || (shards > 0 && (shardHeartbeat <= 0 || shardTTL <= shardHeartbeat)) {
+		log.Error("config_error", slog.String("err", "OUTBOX_RELAY_SHARDS must be >= 0 and OUTBOX_RELAY_SHARD_TTL > OUTBOX_RELAY_SHARD_HEARTBEAT > 0"))
+		os.Exit(2)
+	}
+
+	switch eventFormat {
+	case events.FormatEnvelope, events.FormatCloudEventsStructured, events.FormatCloudEventsBinary:
+	default:
+		log.Error("config_error", slog.String("err", "OUTBOX_RELAY_EVENT_FORMAT must be envelope, cloudevents-structured or cloudevents-binary"))
+		os.Exit(2)
+	}
+
+	retryPolicies, err := retry.ParsePolicies(retrySpec, retryByType)
+	if err != nil {
+		log.Error("config_error", slog.String("err", err.Error()))
+		os.Exit(2)
+	}
+
+	router, err := outbox.LoadRouter(routesFile, topic)
+	if err != nil {
+		log.Error("config_error", slog.String("err", err.Error()))
+		os.Exit(2)
+	}
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
@@ -56,27 +117,42 @@ func main() {
 	defer func() { _ = pg.Close() }()
 
 	store := outbox.NewStore(pg)
-	producer := kafkax.NewProducer(kafkax.ProducerConfig{
-		Brokers:      brokers,
-		Topic:        topic,
-		ClientID:     clientID,
-		WriteTimeout: 5 * time.Second,
-	})
-	defer func() { _ = producer.Close() }()
-
-	var dlqProducer *kafkax.Producer
+	store.MaxPriorityWait = priorityMaxWait
+	// The DLQ goes through the same sink as regular events.
+	topics := router.Topics()
 	if dlqTopic != "" {
-		dlqProducer = kafkax.NewProducer(kafkax.ProducerConfig{
-			Brokers:      brokers,
-			Topic:        dlqTopic,
-			ClientID:     clientID + "-dlq",
-			WriteTimeout: 5 * time.Second,
-		})
-		defer func() { _ = dlqProducer.Close() }()
+		topics = append(topics, dlqTopic)
+	}
+	pub, err := newPublisher(sink, topics)
+	if err != nil {
+		log.Error("config_error", slog.String("err", err.Error()))
+		os.Exit(2)
 	}
 
 	reg := prometheus.NewRegistry()
-	met := outbox.NewMetrics(reg)
+	met := outbox.NewMetrics(reg, router)
+
+	// Worker pool: publish a batch concurrently, one worker per aggregate key (order preserved).
+	if workers > 1 {
+		pub = outbox.NewPoolPublisher(pub, workers, maxInFlight, met)
+	}
+	defer func() { _ = pub.Close() }()
+
+	// Circuit breaker: stop claiming while the sink is down instead of burning attempts.
+	var breaker *outbox.Breaker
+	if breakerThreshold > 0 {
+		breaker = &outbox.Breaker{
+			Threshold: breakerThreshold,
+			Cooldown:  breakerCooldown,
+			OnChange: func(from, to outbox.BreakerState) {
+				met.CircuitState.Set(float64(to))
+				if to == outbox.BreakerOpen {
+					met.CircuitOpensTotal.Inc()
+				}
+				log.Warn("circuit_state_changed", slog.String("from", from.String()), slog.String("to", to.String()))
+			},
+		}
+	}
 
 	// metrics server
 	{
@@ -90,10 +166,18 @@ func main() {
 				http.Error(w, "db unavailable", http.StatusServiceUnavailable)
 				return
 			}
+			if breaker != nil && breaker.State() == outbox.BreakerOpen {
+				http.Error(w, "sink unavailable (circuit open)", http.StatusServiceUnavailable)
+				return
+			}
 			w.WriteHeader(http.StatusOK)
 			_, _ = w.Write([]byte("ready"))
 		})
 		mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+		// Admin API (inspect/requeue/skip/purge): only with a token, it changes the outbox.
+		if adminToken != "" {
+			mux.Handle("/admin/outbox/", newAdminHandler(log, outbox.NewAdmin(pg), adminToken))
+		}
 		metricsSrv := &http.Server{Addr: metricsAddr, Handler: mux}
 		defer func() { _ = metricsSrv.Shutdown(context.Background()) }()
 		go func() {
@@ -104,127 +188,180 @@ func main() {
 		}()
 	}
 
+	// With sharding, cluster-wide maintenance (partitions, retention, see also relay.tick)
+	// runs only on the shard-0 owner.
+	var sharder *outbox.Sharder
+	maintainer := func() bool { return true }
+	if shards > 0 {
+		sharder = &outbox.Sharder{
+			DatabaseURL: dbURL,
+			Shards:      shards,
+			Heartbeat:   shardHeartbeat,
+			TTL:         shardTTL,
+			MemberID:    memberID,
+			Log:         log,
+			Metrics:     met,
+		}
+		maintainer = sharder.Maintainer
+	}
+
+	// Partition maintenance is a no-op until migration 0013 has partitioned the table.
+	pm := &outbox.PartitionManager{
+		DB:      pg,
+		Premake: partitionPremake,
+		Retain:  partitionRetain,
+		Drop:    partitionDrop,
+		Log:     log,
+		Metrics: met,
+		Active:  maintainer,
+	}
+	go pm.Run(ctx)
+
+	// Retention: delete (and optionally archive) finished rows; disabled when RETENTION_MAX_AGE=0.
+	if retentionCfg.MaxAge > 0 {
+		retentionCfg.Active = maintainer
+		job := retention.NewJob(pg, retention.Outbox, retentionCfg, log, retention.NewMetrics(reg))
+		go job.Run(ctx)
+	}
+
 	log.Info("relay_start",
 		slog.Int("batch_size", batchSize),
 		slog.String("poll_interval", pollInterval.String()),
 		slog.String("processing_timeout", processingTimeout.String()),
 		slog.Int("max_attempts", maxAttempts),
-		slog.String("topic", topic),
+		slog.Bool("strict_order", strictOrder),
+		slog.String("priority_max_wait", priorityMaxWait.String()),
+		slog.String("event_format", eventFormat),
+		slog.Bool("admin_api", adminToken != ""),
+		slog.String("retry_policy", retrySpec),
+		slog.String("retry_policy_by_type", retryByType),
+		slog.Int("workers", workers),
+		slog.Int("max_in_flight", maxInFlight),
+		slog.Int("breaker_threshold", breakerThreshold),
+		slog.String("breaker_cooldown", breakerCooldown.String()),
+		slog.Int("shards", shards),
+		slog.String("member_id", memberID),
+		slog.String("sink", sink.Kind),
+		slog.String("topic", router.DefaultTopic),
+		slog.String("routes_file", routesFile),
+		slog.Any("routes", router.Routes),
 		slog.String("dlq_topic", dlqTopic),
+		slog.Bool("listen", listen),
+		slog.String("notify_channel", outbox.DefaultNotifyChannel),
+		slog.Int("partition_premake_days", partitionPremake),
+		slog.String("partition_retain", partitionRetain.String()),
+		slog.Bool("partition_drop", partitionDrop),
+		slog.String("retention_max_age", retentionCfg.MaxAge.String()),
+		slog.String("retention_archive_dir", retentionCfg.ArchiveDir),
 	)
 
-	t := time.NewTicker(pollInterval)
-	defer t.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			log.Info("relay_shutdown")
-			return
-		case <-t.C:
-			if err := tick(ctx, log, store, producer, dlqProducer, met, batchSize, processingTimeout, maxAttempts); err != nil {
-				log.Error("relay_tick_failed", slog.String("err", err.Error()))
-			}
-		}
+	// NOTIFY wake-ups cut latency; the ticker stays as a fallback (and covers retries via next_retry_at).
+	wake := make(chan struct{}, 1)
+	if listen {
+		l := &outbox.Listener{DatabaseURL: dbURL, Log: log, Metrics: met}
+		go l.Run(ctx, wake)
 	}
-}
 
-func tick(ctx context.Context, log *slog.Logger, store *outbox.Store, producer *kafkax.Producer, dlqProducer *kafkax.Producer, met *outbox.Metrics, batchSize int, processingTimeout time.Duration, maxAttempts int) error {
-	if _, err := store.ResetStuck(ctx, processingTimeout); err != nil {
-		return err
+	// Sharding: claim only aggregates of the shards this replica holds; newly acquired shards wake the loop.
+	if sharder != nil {
+		go sharder.Run(ctx, wake)
 	}
 
-	if lag, err := store.LagSeconds(ctx); err == nil {
-		met.LagSeconds.Set(lag)
-	}
+	t := time.NewTicker(pollInterval)
+	defer t.Stop()
 
-	rows, err := store.ClaimPending(ctx, batchSize)
-	if err != nil {
-		return err
+	rl := &relay{
+		log:      log,
+		store:    store,
+		router:   router,
+		pub:      pub,
+		dlqTopic: dlqTopic,
+		met:      met,
+		shards:   sharder,
+		breaker:  breaker,
+		retry:    retryPolicies,
+		clock:    retry.SystemClock,
+		cfg: tickConfig{
+			BatchSize:         batchSize,
+			ProcessingTimeout: processingTimeout,
+			MaxAttempts:       maxAttempts,
+			StrictOrder:       strictOrder,
+			Format:            eventFormat,
+		},
+		stop: ctx,
 	}
 
-	for _, e := range rows {
-		var ridHolder struct {
-			RequestID string `json:"request_id"`
-		}
-		_ = json.Unmarshal(e.Payload, &ridHolder)
-
-		env := events.Envelope{
-			EventID:     e.EventID,
-			EventType:   e.EventType,
-			OccurredAt:  e.CreatedAt,
-			Aggregate:   e.Aggregate,
-			AggregateID: e.AggregateID,
-			RequestID:   ridHolder.RequestID,
-			Payload:     e.Payload,
-		}
-		b, err := json.Marshal(env)
-		if err != nil {
-			dead := maybeDead(ctx, log, store, dlqProducer, met, e, env, maxAttempts, "marshal: "+err.Error())
-			if !dead {
-				_ = store.MarkFailed(ctx, e.ID, time.Now().Add(backoff(e.Attempts)), "marshal: "+err.Error())
-				met.FailedTotal.WithLabelValues(e.EventType).Inc()
-			}
-			continue
+	// Graceful shutdown: the signal (ctx, rl.stop) only stops claiming, also in a tick
+	// that is already running. A batch already claimed keeps publishing on workCtx, which is cancelled drainTimeout after the signal; whatever is
+	// still unpublished by then goes back to pending without losing an attempt (see tick).
+	workCtx, cancelWork := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelWork()
+	go func() {
+		<-ctx.Done()
+		select {
+		case <-time.After(drainTimeout):
+			cancelWork()
+		case <-workCtx.Done():
 		}
+	}()
 
-		if err := producer.Produce(ctx, []byte(e.AggregateID), b, 5*time.Second); err != nil {
-			log.Warn("publish_failed",
-				slog.Int64("outbox_id", e.ID),
-				slog.String("event_type", e.EventType),
-				slog.Int("attempt", e.Attempts),
-				slog.String("err", err.Error()),
-			)
-			dead := maybeDead(ctx, log, store, dlqProducer, met, e, env, maxAttempts, "kafka: "+err.Error())
-			if !dead {
-				next := time.Now().Add(backoff(e.Attempts))
-				_ = store.MarkFailed(ctx, e.ID, next, "kafka: "+err.Error())
-				met.FailedTotal.WithLabelValues(e.EventType).Inc()
+	drain := func() {
+		// Keep claiming while batches come back full, so a burst is flushed without waiting for the ticker.
+		for ctx.Err() == nil {
+			n, err := rl.tick(workCtx)
+			if err != nil {
+				log.Error("relay_tick_failed", slog.String("err", err.Error()))
+				return
+			}
+			if n < batchSize {
+				return
 			}
-			continue
 		}
+	}
 
-		if err := store.MarkSent(ctx, e.ID); err != nil {
-			log.Error("mark_sent_failed", slog.Int64("outbox_id", e.ID), slog.String("err", err.Error()))
+	for {
+		select {
+		case <-ctx.Done():
+			// tick is synchronous, so any in-flight batch has been finished or released by now.
+			log.Info("relay_shutdown", slog.String("drain_timeout", drainTimeout.String()))
+			return
+		case <-wake:
+			drain()
+		case <-t.C:
+			drain()
 		}
-		met.PublishedTotal.WithLabelValues(e.EventType).Inc()
 	}
-
-	return nil
 }
 
-func backoff(attempt int) time.Duration {
-	if attempt <= 1 {
-		return 500 * time.Millisecond
-	}
-	d := time.Duration(1<<uint(min(attempt-1, 6))) * time.Second
-	if d > 60*time.Second {
-		d = 60 * time.Second
-	}
-	return d
+type sinkConfig struct {
+	Kind           string
+	Brokers        []string
+	ClientID       string
+	WebhookURL     string
+	WebhookTimeout time.Duration
+	NATSURL        string
+	File           string
 }
 
-func maybeDead(ctx context.Context, log *slog.Logger, store *outbox.Store, dlqProducer *kafkax.Producer, met *outbox.Metrics, e outbox.Event, env events.Envelope, maxAttempts int, errMsg string) bool {
-	if maxAttempts > 0 && e.Attempts >= maxAttempts {
-		// Best-effort DLQ publish; even if it fails we still mark DB row as failed to avoid infinite retries.
-		if dlqProducer != nil {
-			dlq := struct {
-				Error    string          `json:"error"`
-				Envelope events.Envelope `json:"envelope"`
-			}{
-				Error:    errMsg,
-				Envelope: env,
-			}
-			if b, err := json.Marshal(dlq); err == nil {
-				_ = dlqProducer.Produce(ctx, []byte(e.AggregateID), b, 5*time.Second)
-			}
-		}
-
-		if err := store.MarkDead(ctx, e.ID, errMsg); err != nil {
-			log.Error("mark_dead_failed", slog.Int64("outbox_id", e.ID), slog.String("err", err.Error()))
+// newPublisher builds the sink selected by OUTBOX_RELAY_SINK; topics are the Kafka topics to open producers for.
+func newPublisher(cfg sinkConfig, topics []string) (outbox.Publisher, error) {
+	switch cfg.Kind {
+	case outbox.SinkKafka:
+		return outbox.NewKafkaPublisher(kafkax.ProducerConfig{
+			Brokers:      cfg.Brokers,
+			ClientID:     cfg.ClientID,
+			WriteTimeout: 5 * time.Second,
+		}, topics), nil
+	case outbox.SinkWebhook:
+		if cfg.WebhookURL == "" {
+			return nil, fmt.Errorf("OUTBOX_RELAY_WEBHOOK_URL is empty")
 		}
-		met.DeadTotal.WithLabelValues(e.EventType).Inc()
-		return true
+		return outbox.NewWebhookPublisher(cfg.WebhookURL, cfg.WebhookTimeout), nil
+	case outbox.SinkNATS:
+		return outbox.NewNATSPublisher(cfg.NATSURL, 5*time.Second)
+	case outbox.SinkFile:
+		return outbox.NewFilePublisher(cfg.File)
+	default:
+		return nil, fmt.Errorf("OUTBOX_RELAY_SINK: unknown sink %q", cfg.Kind)
 	}
-	return false
 }
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/k1networth/servicedesk-lite/internal/outbox"
+	"github.com/k1networth/servicedesk-lite/internal/shared/events"
+	"github.com/k1networth/servicedesk-lite/internal/shared/retry"
+)
+
+type tickConfig struct {
+	BatchSize         int
+	ProcessingTimeout time.Duration
+	MaxAttempts       int
+	// StrictOrder claims only the head event of each aggregate (see Store.ClaimPendingOrdered).
+	StrictOrder bool
+	// Format is the wire format: events.FormatEnvelope (default) or one of the CloudEvents modes.
+	Format string
+}
+
+// relayStore is the part of *outbox.Store used by tick (faked in tests).
+type relayStore interface {
+	ResetStuck(ctx context.Context, processingTimeout time.Duration) (int64, error)
+	LagSecondsByPriority(ctx context.Context) (map[int]float64, error)
+	BlockedAggregates(ctx context.Context) (int64, error)
+	ClaimPending(ctx context.Context, batchSize int, shards outbox.ShardFilter) ([]outbox.Event, error)
+	ClaimPendingOrdered(ctx context.Context, batchSize int, shards outbox.ShardFilter) ([]outbox.Event, error)
+	MarkSentBatch(ctx context.Context, evs []outbox.Event) error
+	Release(ctx context.Context, evs []outbox.Event, errMsg string) error
+	MarkFailed(ctx context.Context, e outbox.Event, nextRetryAt time.Time, errMsg string) error
+	MarkDead(ctx context.Context, e outbox.Event, errMsg string) error
+}
+
+type relay struct {
+	log      *slog.Logger
+	store    relayStore
+	router   *outbox.Router
+	pub      outbox.Publisher
+	dlqTopic string // empty when KAFKA_DLQ_TOPIC is not set
+	met      *outbox.Metrics
+	shards   *outbox.Sharder // nil when OUTBOX_RELAY_SHARDS=0
+	breaker  *outbox.Breaker // nil when OUTBOX_RELAY_BREAKER_THRESHOLD=0
+	retry    retry.Policies
+	clock    retry.Clock
+	cfg      tickConfig
+	// stop is cancelled by the shutdown signal, while tick's own ctx lives on for the
+	// drain: a tick that sees it cancelled claims nothing new. nil: never stops.
+	stop context.Context
+}
+
+// tick runs one relay cycle and returns the number of claimed events.
+func (r *relay) tick(ctx context.Context) (int, error) {
+	var filter outbox.ShardFilter
+	if r.shards != nil {
+		var done func()
+		filter, done = r.shards.Lease()
+		defer done()
+	}
+
+	// Cluster-wide maintenance: everyone without sharding, only the shard-0 owner with it.
+	if r.shards == nil || r.shards.Maintainer() {
+		if err := r.maintain(ctx); err != nil {
+			return 0, err
+		}
+	} else {
+		r.clearMaintenanceGauges()
+	}
+
+	batchSize := r.cfg.BatchSize
+	var probe bool
+	if r.breaker != nil {
+		var ok bool
+		ok, probe = r.breaker.Allow()
+		if !ok {
+			return 0, nil
+		}
+		if probe {
+			batchSize = 1
+		}
+	}
+
+	if r.stop != nil && r.stop.Err() != nil {
+		return 0, nil
+	}
+	claim := r.store.ClaimPending
+	if r.cfg.StrictOrder {
+		claim = r.store.ClaimPendingOrdered
+	}
+	rows, err := claim(ctx, batchSize, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	batch := make([]outbox.Event, 0, len(rows))
+	envs := make([]events.Envelope, 0, len(rows))
+	msgs := make([]outbox.Message, 0, len(rows))
+	for _, e := range rows {
+		var ridHolder struct {
+			RequestID   string `json:"request_id"`
+			Traceparent string `json:"traceparent"`
+		}
+		_ = json.Unmarshal(e.Payload, &ridHolder)
+
+		// Rows written by outbox.Writer carry metadata in columns; older rows only in the payload.
+		if e.RequestID == "" {
+			e.RequestID = ridHolder.RequestID
+		}
+		if e.Traceparent == "" {
+			e.Traceparent = ridHolder.Traceparent
+		}
+
+		env := events.Envelope{
+			EventID:       e.EventID,
+			EventType:     e.EventType,
+			SchemaVersion: e.SchemaVersion,
+			OccurredAt:    e.CreatedAt,
+			Aggregate:     e.Aggregate,
+			AggregateID:   e.AggregateID,
+			RequestID:     e.RequestID,
+			TenantID:      e.TenantID,
+			Payload:       e.Payload,
+			PayloadRef:    e.PayloadRef,
+		}
+
+		// A payload that breaks its schema will not get better with retries.
+		// Types without a registered schema (other producers) pass through, and offloaded
+		// payloads were validated by outbox.Writer before they were stored.
+		if e.PayloadRef == nil {
+			if err := events.ValidatePayload(e.EventType, e.SchemaVersion, e.Payload); err != nil && !errors.Is(err, events.ErrNoSchema) {
+				r.log.Error("outbox_payload_invalid", slog.Int64("outbox_id", e.ID), slog.String("event_type", e.EventType), slog.String("err", err.Error()))
+				r.dead(ctx, e, env, "invalid payload: "+err.Error())
+				continue
+			}
+		}
+
+		tp := e.Traceparent
+		if !events.ValidTraceparent(tp) {
+			tp = events.NewTraceparent()
+		}
+		b, hs, err := r.encode(env, tp)
+		if err != nil {
+			r.fail(ctx, e, env, "marshal: "+err.Error())
+			continue
+		}
+
+		batch = append(batch, e)
+		envs = append(envs, env)
+		msgs = append(msgs, outbox.Message{
+			Topic:   r.router.Topic(e.EventType),
+			Key:     []byte(e.AggregateID),
+			Value:   b,
+			Headers: hs,
+		})
+	}
+
+	// errs[i] belongs to batch[i]; the publisher batches per topic itself.
+	errs := r.pub.Publish(ctx, msgs)
+
+	// Bookkeeping must land even if ctx was cancelled by the drain timeout mid-publish.
+	fctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	sent := make([]outbox.Event, 0, len(batch))
+	var released, interrupted, skipped []outbox.Event
+	var unavailable []int // batch indexes that failed because the sink was unavailable
+	reached := false      // some publish failed for a reason other than the sink being down
+	failPublish := func(i int, err error) {
+		e := batch[i]
+		r.log.Warn("publish_failed",
+			slog.Int64("outbox_id", e.ID),
+			slog.String("event_type", e.EventType),
+			slog.String("topic", msgs[i].Topic),
+			slog.Int("attempt", e.Attempts),
+			slog.String("err", err.Error()),
+		)
+		r.fail(fctx, e, envs[i], "publish: "+err.Error())
+	}
+	for i, e := range batch {
+		err := errs[i]
+		switch {
+		case err == nil:
+			sent = append(sent, e)
+			r.met.PublishedTotal.WithLabelValues(e.EventType, msgs[i].Topic).Inc()
+		case ctx.Err() != nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)):
+			// Shutdown cut the publish short: hand the event back untouched.
+			interrupted = append(interrupted, e)
+		case errors.Is(err, outbox.ErrKeySkipped):
+			// Never reached the sink (an earlier event of its key failed): no attempt charged.
+			skipped = append(skipped, e)
+		case r.breaker != nil && outbox.IsUnavailable(err):
+			unavailable = append(unavailable, i)
+		default:
+			reached = true
+			failPublish(i, err)
+		}
+	}
+
+	// The sink is down only if nothing got through: then the events are not at fault and
+	// get their attempt back. An unavailable error next to successes (a 429, a partition
+	// without a leader) is the message's own problem and backs off like any failure.
+	sinkDown := len(unavailable) > 0 && len(sent) == 0 && !reached
+	for _, i := range unavailable {
+		if sinkDown {
+			released = append(released, batch[i])
+			continue
+		}
+		failPublish(i, errs[i])
+	}
+
+	if err := r.store.MarkSentBatch(fctx, sent); err != nil {
+		r.log.Error("mark_sent_failed", slog.Int("count", len(sent)), slog.String("err", err.Error()))
+	}
+
+	// A probe must always be resolved, or the relay would stay half-open at batch size 1.
+	if r.breaker != nil {
+		switch {
+		case len(sent) > 0 || reached:
+			r.breaker.Success()
+		case sinkDown:
+			r.breaker.Failure()
+		case probe:
+			// Nothing was published (empty claim, all rows dead-lettered): no sign the sink is down.
+			r.breaker.Success()
+		}
+	}
+	if sinkDown {
+		err := errs[unavailable[0]]
+		r.log.Warn("publish_unavailable", slog.Int("released", len(released)), slog.String("err", err.Error()))
+		r.release(fctx, released, "unavailable", "publish: "+err.Error())
+	}
+	if len(skipped) > 0 {
+		r.release(fctx, skipped, "key_skipped", "publish: not attempted, an earlier event of the aggregate failed")
+	}
+	if len(interrupted) > 0 {
+		r.log.Warn("publish_interrupted", slog.Int("released", len(interrupted)))
+		r.release(fctx, interrupted, "shutdown", "relay shutdown")
+	}
+
+	return len(rows), nil
+}
+
+// envelopeHeaders duplicates routing fields of env as transport headers; empty values are skipped.
+func envelopeHeaders(env events.Envelope, traceparent string) []outbox.Header {
+	hs := []outbox.Header{
+		{Key: events.HeaderEventID, Value: env.EventID},
+		{Key: events.HeaderEventType, Value: env.EventType},
+		{Key: events.HeaderContentType, Value: events.ContentTypeJSON},
+	}
+	if env.SchemaVersion > 0 {
+		hs = append(hs, outbox.Header{Key: events.HeaderSchemaVersion, Value: strconv.Itoa(env.SchemaVersion)})
+	}
+	if env.RequestID != "" {
+		hs = append(hs, outbox.Header{Key: events.HeaderRequestID, Value: env.RequestID})
+	}
+	if env.TenantID != "" {
+		hs = append(hs, outbox.Header{Key: events.HeaderTenantID, Value: env.TenantID})
+	}
+	if traceparent != "" {
+		hs = append(hs, outbox.Header{Key: events.HeaderTraceparent, Value: traceparent})
+	}
+	return hs
+}
+
+// encode serializes env in the configured wire format. The envelope headers are sent in
+// every format so header-based filtering keeps working; CloudEvents modes override
+// content-type and binary mode adds the ce_* attributes.
+func (r *relay) encode(env events.Envelope, traceparent string) ([]byte, []outbox.Header, error) {
+	hs := envelopeHeaders(env, traceparent)
+	switch r.cfg.Format {
+	case events.FormatCloudEventsStructured:
+		b, err := json.Marshal(events.ToCloudEvent(env))
+		setHeader(hs, events.HeaderContentType, events.ContentTypeCloudEvents)
+		return b, hs, err
+	case events.FormatCloudEventsBinary:
+		ce := events.ToCloudEvent(env)
+		for _, h := range ce.BinaryHeaders() {
+			hs = append(hs, outbox.Header{Key: h[0], Value: h[1]})
+		}
+		return ce.Data, hs, nil
+	default:
+		b, err := json.Marshal(env)
+		return b, hs, err
+	}
+}
+
+func setHeader(hs []outbox.Header, key, value string) {
+	for i := range hs {
+		if hs[i].Key == key {
+			hs[i].Value = value
+		}
+	}
+}
+
+func (r *relay) release(ctx context.Context, evs []outbox.Event, reason, errMsg string) {
+	if err := r.store.Release(ctx, evs, errMsg); err != nil {
+		r.log.Error("release_failed", slog.Int("count", len(evs)), slog.String("reason", reason), slog.String("err", err.Error()))
+		return
+	}
+	r.met.ReleasedTotal.WithLabelValues(reason).Add(float64(len(evs)))
+}
+
+func (r *relay) maintain(ctx context.Context) error {
+	if _, err := r.store.ResetStuck(ctx, r.cfg.ProcessingTimeout); err != nil {
+		return err
+	}
+
+	if lags, err := r.store.LagSecondsByPriority(ctx); err == nil {
+		var oldest float64
+		for _, p := range outbox.Priorities {
+			r.met.PriorityLagSeconds.WithLabelValues(outbox.PriorityName(p)).Set(lags[p])
+		}
+		for _, lag := range lags {
+			oldest = math.Max(oldest, lag)
+		}
+		r.met.LagSeconds.Set(oldest)
+	}
+
+	if r.cfg.StrictOrder {
+		if n, err := r.store.BlockedAggregates(ctx); err == nil {
+			r.met.BlockedAggregates.Set(float64(n))
+		}
+	}
+	return nil
+}
+
+// clearMaintenanceGauges zeroes the cluster-wide gauges on a replica that is not the
+// maintainer (any more), so max() over replicas does not keep showing its stale values.
+func (r *relay) clearMaintenanceGauges() {
+	r.met.LagSeconds.Set(0)
+	for _, p := range outbox.Priorities {
+		r.met.PriorityLagSeconds.WithLabelValues(outbox.PriorityName(p)).Set(0)
+	}
+	r.met.BlockedAggregates.Set(0)
+}
+
+// fail reschedules e with backoff, or moves it to dead once attempts are exhausted.
+func (r *relay) fail(ctx context.Context, e outbox.Event, env events.Envelope, errMsg string) {
+	if r.maybeDead(ctx, e, env, errMsg) {
+		return
+	}
+	// The relay keeps no per-event state between claims, so decorrelated policies get prev=0.
+	delay := r.retry.For(e.EventType).Delay(e.Attempts, 0)
+	_ = r.store.MarkFailed(ctx, e, r.clock.Now().Add(delay), errMsg)
+	r.met.FailedTotal.WithLabelValues(e.EventType, r.router.Topic(e.EventType)).Inc()
+}
+
+func (r *relay) maybeDead(ctx context.Context, e outbox.Event, env events.Envelope, errMsg string) bool {
+	if r.cfg.MaxAttempts > 0 && e.Attempts >= r.cfg.MaxAttempts {
+		r.dead(ctx, e, env, errMsg)
+		return true
+	}
+	return false
+}
+
+// dead moves e to dead without further attempts.
+func (r *relay) dead(ctx context.Context, e outbox.Event, env events.Envelope, errMsg string) {
+	// Best-effort DLQ publish; even if it fails we still mark DB row as failed to avoid infinite retries.
+	if r.dlqTopic != "" {
+		dlq := struct {
+			Error    string          `json:"error"`
+			Envelope events.Envelope `json:"envelope"`
+		}{
+			Error:    errMsg,
+			Envelope: env,
+		}
+		if b, err := json.Marshal(dlq); err == nil {
+			_ = r.pub.Publish(ctx, []outbox.Message{{
+				Topic:   r.dlqTopic,
+				Key:     []byte(e.AggregateID),
+				Value:   b,
+				Headers: envelopeHeaders(env, ""),
+			}})
+		}
+	}
+
+	if err := r.store.MarkDead(ctx, e, errMsg); err != nil {
+		r.log.Error("mark_dead_failed", slog.Int64("outbox_id", e.ID), slog.String("err", err.Error()))
+	}
+	r.met.DeadTotal.WithLabelValues(e.EventType, r.router.Topic(e.EventType)).Inc()
+}
@@ -0,0 +1,551 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/k1networth/servicedesk-lite/internal/outbox"
+	"github.com/k1networth/servicedesk-lite/internal/shared/events"
+	"github.com/k1networth/servicedesk-lite/internal/shared/retry"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// fakeStore hands out pending events once and records how each one ended.
+type fakeStore struct {
+	pending  []outbox.Event
+	sent     []int64
+	failed   []int64
+	retryAt  []time.Time
+	dead     []int64
+	released []int64
+	lags     map[int]float64
+}
+
+func (s *fakeStore) ResetStuck(context.Context, time.Duration) (int64, error) { return 0, nil }
+func (s *fakeStore) LagSecondsByPriority(context.Context) (map[int]float64, error) {
+	return s.lags, nil
+}
+func (s *fakeStore) BlockedAggregates(context.Context) (int64, error) { return 0, nil }
+
+func (s *fakeStore) ClaimPending(_ context.Context, batchSize int, _ outbox.ShardFilter) ([]outbox.Event, error) {
+	n := min(batchSize, len(s.pending))
+	out := s.pending[:n]
+	s.pending = s.pending[n:]
+	return out, nil
+}
+
+func (s *fakeStore) ClaimPendingOrdered(ctx context.Context, batchSize int, f outbox.ShardFilter) ([]outbox.Event, error) {
+	return s.ClaimPending(ctx, batchSize, f)
+}
+
+func (s *fakeStore) MarkSentBatch(_ context.Context, evs []outbox.Event) error {
+	for _, e := range evs {
+		s.sent = append(s.sent, e.ID)
+	}
+	return nil
+}
+
+func (s *fakeStore) Release(_ context.Context, evs []outbox.Event, _ string) error {
+	for _, e := range evs {
+		s.released = append(s.released, e.ID)
+	}
+	return nil
+}
+
+func (s *fakeStore) MarkFailed(_ context.Context, e outbox.Event, nextRetryAt time.Time, _ string) error {
+	s.failed = append(s.failed, e.ID)
+	s.retryAt = append(s.retryAt, nextRetryAt)
+	return nil
+}
+
+func (s *fakeStore) MarkDead(_ context.Context, e outbox.Event, _ string) error {
+	s.dead = append(s.dead, e.ID)
+	return nil
+}
+
+// memPublisher keeps published messages in memory and fails those listed in fail as "topic/key"
+// (unavailable: as unavailable). With down set every message fails as unavailable; with
+// block set Publish waits for ctx.
+type memPublisher struct {
+	msgs        []outbox.Message
+	fail        map[string]bool
+	unavailable map[string]bool
+	down        bool
+	block       bool
+}
+
+func (p *memPublisher) Publish(ctx context.Context, msgs []outbox.Message) []error {
+	errs := make([]error, len(msgs))
+	if p.block {
+		<-ctx.Done()
+		for i := range errs {
+			errs[i] = ctx.Err()
+		}
+		return errs
+	}
+	for i, m := range msgs {
+		if p.down || p.unavailable[m.Topic+"/"+string(m.Key)] {
+			errs[i] = &outbox.UnavailableError{Err: errors.New("dial tcp: connection refused")}
+			continue
+		}
+		if p.fail[m.Topic+"/"+string(m.Key)] {
+			errs[i] = errors.New("broker said no")
+			continue
+		}
+		p.msgs = append(p.msgs, m)
+	}
+	return errs
+}
+
+func (p *memPublisher) Close() error { return nil }
+
+// publishFunc answers Publish with a function of the batch.
+type publishFunc func(msgs []outbox.Message) []error
+
+func (f publishFunc) Publish(_ context.Context, msgs []outbox.Message) []error { return f(msgs) }
+func (f publishFunc) Close() error                                             { return nil }
+
+func newTestRelay(t *testing.T, store *fakeStore, pub *memPublisher, router *outbox.Router) *relay {
+	t.Helper()
+	if err := router.Validate(); err != nil {
+		t.Fatalf("router: %v", err)
+	}
+	return &relay{
+		log:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+		store:    store,
+		router:   router,
+		pub:      pub,
+		dlqTopic: "tickets.dlq",
+		met:      outbox.NewMetrics(prometheus.NewRegistry(), router),
+		retry:    retry.Policies{Default: retry.Fixed{Interval: time.Second}},
+		clock:    fixedClock{},
+		cfg:      tickConfig{BatchSize: 10, MaxAttempts: 3},
+	}
+}
+
+var testNow = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+type fixedClock struct{}
+
+func (fixedClock) Now() time.Time                       { return testNow }
+func (fixedClock) After(time.Duration) <-chan time.Time { return nil }
+
+// testEvent builds an event with a schema-valid payload of the current version.
+func testEvent(id int64, aggregateID, eventType string, attempts int) outbox.Event {
+	var payload any = events.TicketCreated{
+		TicketID: aggregateID, Title: "Printer on fire", Status: "new", CreatedAt: testNow, RequestID: "req-1",
+	}
+	if eventType == events.EventTypeTicketWorklogAdded {
+		payload = events.TicketWorklogAdded{
+			TicketID: aggregateID, WorklogID: id, Agent: "alice", DurationMinutes: 15, CreatedAt: testNow, RequestID: "req-1",
+		}
+	}
+	b, _ := json.Marshal(payload)
+	return outbox.Event{
+		ID:            id,
+		EventID:       "evt-" + aggregateID,
+		Aggregate:     "ticket",
+		AggregateID:   aggregateID,
+		EventType:     eventType,
+		SchemaVersion: events.CurrentSchemaVersion(eventType),
+		Payload:       b,
+		CreatedAt:     time.Now(),
+		Attempts:      attempts,
+	}
+}
+
+func TestTickRoutesAndMarksSent(t *testing.T) {
+	store := &fakeStore{pending: []outbox.Event{
+		testEvent(1, "a", "ticket.created", 1),
+		testEvent(2, "b", "ticket.worklog_added", 1),
+	}}
+	pub := &memPublisher{}
+	rl := newTestRelay(t, store, pub, &outbox.Router{
+		DefaultTopic: "tickets.events",
+		Routes:       []outbox.Route{{Pattern: "ticket.worklog_*", Topic: "tickets.billing"}},
+	})
+
+	n, err := rl.tick(context.Background())
+	if err != nil || n != 2 {
+		t.Fatalf("tick: n=%d err=%v", n, err)
+	}
+	if len(store.sent) != 2 {
+		t.Fatalf("expected 2 sent, got %v", store.sent)
+	}
+	if pub.msgs[0].Topic != "tickets.events" || pub.msgs[1].Topic != "tickets.billing" {
+		t.Fatalf("unexpected topics: %q, %q", pub.msgs[0].Topic, pub.msgs[1].Topic)
+	}
+
+	var env struct {
+		EventID   string `json:"event_id"`
+		RequestID string `json:"request_id"`
+	}
+	if err := json.Unmarshal(pub.msgs[0].Value, &env); err != nil {
+		t.Fatalf("decode envelope: %v", err)
+	}
+	if env.EventID != "evt-a" || env.RequestID != "req-1" {
+		t.Fatalf("unexpected envelope: %+v", env)
+	}
+
+	hs := map[string]string{}
+	for _, h := range pub.msgs[0].Headers {
+		hs[h.Key] = h.Value
+	}
+	if hs[events.HeaderEventID] != "evt-a" || hs[events.HeaderEventType] != "ticket.created" ||
+		hs[events.HeaderRequestID] != "req-1" || hs[events.HeaderContentType] != events.ContentTypeJSON ||
+		hs[events.HeaderSchemaVersion] == "" || !events.ValidTraceparent(hs[events.HeaderTraceparent]) {
+		t.Fatalf("unexpected headers: %v", hs)
+	}
+}
+
+func TestTickEncodesCloudEvents(t *testing.T) {
+	for _, format := range []string{events.FormatCloudEventsStructured, events.FormatCloudEventsBinary} {
+		t.Run(format, func(t *testing.T) {
+			store := &fakeStore{pending: []outbox.Event{testEvent(1, "a", "ticket.created", 1)}}
+			pub := &memPublisher{}
+			rl := newTestRelay(t, store, pub, &outbox.Router{DefaultTopic: "tickets.events"})
+			rl.cfg.Format = format
+
+			if n, err := rl.tick(context.Background()); err != nil || n != 1 {
+				t.Fatalf("tick: n=%d err=%v", n, err)
+			}
+
+			m := pub.msgs[0]
+			header := func(key string) (string, bool) {
+				for _, h := range m.Headers {
+					if h.Key == key {
+						return h.Value, true
+					}
+				}
+				return "", false
+			}
+			env, err := events.Decode(m.Value, header)
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if env.EventID != "evt-a" || env.EventType != "ticket.created" || env.Aggregate != "ticket" ||
+				env.AggregateID != "a" || env.RequestID != "req-1" {
+				t.Fatalf("unexpected envelope: %+v", env)
+			}
+
+			_, binary := header(events.CEHeaderSpecVersion)
+			ct, _ := header(events.HeaderContentType)
+			if binary != (format == events.FormatCloudEventsBinary) {
+				t.Fatalf("ce_specversion header present=%v for %s", binary, format)
+			}
+			if format == events.FormatCloudEventsStructured && ct != events.ContentTypeCloudEvents {
+				t.Fatalf("unexpected content-type %q", ct)
+			}
+		})
+	}
+}
+
+func TestTickDeadLettersInvalidPayload(t *testing.T) {
+	bad := testEvent(1, "a", "ticket.created", 1)
+	bad.Payload = json.RawMessage(`{"ticket_id":"a","title":"x","status":"new"}`)
+	foreign := testEvent(2, "b", "billing.invoice_paid", 1)
+	foreign.Payload = json.RawMessage(`{"anything":true}`)
+	store := &fakeStore{pending: []outbox.Event{bad, foreign}}
+	pub := &memPublisher{}
+	rl := newTestRelay(t, store, pub, &outbox.Router{DefaultTopic: "tickets.events"})
+
+	if _, err := rl.tick(context.Background()); err != nil {
+		t.Fatalf("tick: %v", err)
+	}
+	if len(store.dead) != 1 || store.dead[0] != 1 || len(store.failed) != 0 {
+		t.Fatalf("expected the invalid event dead on first attempt, got dead=%v failed=%v", store.dead, store.failed)
+	}
+	if len(store.sent) != 1 || store.sent[0] != 2 {
+		t.Fatalf("expected the event without a schema to pass through, got %v", store.sent)
+	}
+
+	var dlq struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(pub.msgs[0].Value, &dlq); err != nil || pub.msgs[0].Topic != "tickets.dlq" {
+		t.Fatalf("expected DLQ message first, got %+v", pub.msgs[0])
+	}
+	if !strings.Contains(dlq.Error, `missing required property "requester_org"`) {
+		t.Fatalf("unclear DLQ error: %q", dlq.Error)
+	}
+}
+
+func TestMaintainReportsLagPerPriority(t *testing.T) {
+	store := &fakeStore{lags: map[int]float64{outbox.PriorityLow: 900, outbox.PriorityHigh: 2}}
+	rl := newTestRelay(t, store, &memPublisher{}, &outbox.Router{DefaultTopic: "tickets.events"})
+
+	if err := rl.maintain(context.Background()); err != nil {
+		t.Fatalf("maintain: %v", err)
+	}
+	got := map[string]float64{}
+	for _, p := range outbox.Priorities {
+		got[outbox.PriorityName(p)] = testutil.ToFloat64(rl.met.PriorityLagSeconds.WithLabelValues(outbox.PriorityName(p)))
+	}
+	if got["low"] != 900 || got["normal"] != 0 || got["high"] != 2 {
+		t.Fatalf("unexpected per-priority lag: %v", got)
+	}
+	if lag := testutil.ToFloat64(rl.met.LagSeconds); lag != 900 {
+		t.Fatalf("expected overall lag to be the oldest lane, got %v", lag)
+	}
+}
+
+func TestTickClearsLagGaugesWithoutShardZero(t *testing.T) {
+	store := &fakeStore{lags: map[int]float64{outbox.PriorityLow: 900}}
+	rl := newTestRelay(t, store, &memPublisher{}, &outbox.Router{DefaultTopic: "tickets.events"})
+	if err := rl.maintain(context.Background()); err != nil {
+		t.Fatalf("maintain: %v", err)
+	}
+	rl.met.BlockedAggregates.Set(3)
+
+	// A replica that lost shard 0 must not keep exporting its last lag.
+	rl.shards = &outbox.Sharder{Shards: 4}
+	if _, err := rl.tick(context.Background()); err != nil {
+		t.Fatalf("tick: %v", err)
+	}
+	if lag := testutil.ToFloat64(rl.met.LagSeconds); lag != 0 {
+		t.Fatalf("expected outbox_lag_seconds reset, got %v", lag)
+	}
+	if lag := testutil.ToFloat64(rl.met.PriorityLagSeconds.WithLabelValues("low")); lag != 0 {
+		t.Fatalf("expected outbox_priority_lag_seconds reset, got %v", lag)
+	}
+	if n := testutil.ToFloat64(rl.met.BlockedAggregates); n != 0 {
+		t.Fatalf("expected outbox_blocked_aggregates reset, got %v", n)
+	}
+}
+
+func TestTickRetriesAndDeadLetters(t *testing.T) {
+	store := &fakeStore{pending: []outbox.Event{
+		testEvent(1, "retry", "ticket.created", 1),
+		testEvent(2, "dead", "ticket.created", 3),
+		testEvent(3, "ok", "ticket.created", 1),
+	}}
+	pub := &memPublisher{fail: map[string]bool{"tickets.events/retry": true, "tickets.events/dead": true}}
+	rl := newTestRelay(t, store, pub, &outbox.Router{DefaultTopic: "tickets.events"})
+
+	if _, err := rl.tick(context.Background()); err != nil {
+		t.Fatalf("tick: %v", err)
+	}
+
+	if len(store.sent) != 1 || store.sent[0] != 3 {
+		t.Fatalf("expected only 3 sent, got %v", store.sent)
+	}
+	if len(store.failed) != 1 || store.failed[0] != 1 {
+		t.Fatalf("expected 1 rescheduled, got %v", store.failed)
+	}
+	if len(store.dead) != 1 || store.dead[0] != 2 {
+		t.Fatalf("expected 2 dead, got %v", store.dead)
+	}
+	if last := pub.msgs[len(pub.msgs)-1]; last.Topic != "tickets.dlq" {
+		t.Fatalf("expected DLQ message last, got topic %q", last.Topic)
+	}
+}
+
+func TestTickBreakerReleasesWithoutChargingAttempts(t *testing.T) {
+	store := &fakeStore{}
+	for i := range 4 {
+		store.pending = append(store.pending, testEvent(int64(i+1), "a", "ticket.created", 1))
+	}
+	pub := &memPublisher{down: true}
+	rl := newTestRelay(t, store, pub, &outbox.Router{DefaultTopic: "tickets.events"})
+	rl.cfg.BatchSize = 2
+	rl.breaker = &outbox.Breaker{Threshold: 1, Cooldown: time.Hour}
+
+	if n, err := rl.tick(context.Background()); err != nil || n != 2 {
+		t.Fatalf("tick: n=%d err=%v", n, err)
+	}
+	if len(store.released) != 2 || len(store.failed) != 0 || len(store.dead) != 0 {
+		t.Fatalf("expected 2 released and none failed/dead, got released=%v failed=%v dead=%v",
+			store.released, store.failed, store.dead)
+	}
+	if rl.breaker.State() != outbox.BreakerOpen {
+		t.Fatalf("expected open breaker, got %s", rl.breaker.State())
+	}
+
+	// Open: nothing is claimed.
+	if n, _ := rl.tick(context.Background()); n != 0 || len(store.pending) != 2 {
+		t.Fatalf("expected no claim while open, n=%d pending=%d", n, len(store.pending))
+	}
+}
+
+func TestTickBacksOffIsolatedUnavailableMessage(t *testing.T) {
+	store := &fakeStore{}
+	pub := &memPublisher{unavailable: map[string]bool{"tickets.events/throttled": true}}
+	rl := newTestRelay(t, store, pub, &outbox.Router{DefaultTopic: "tickets.events"})
+	rl.breaker = &outbox.Breaker{Threshold: 1, Cooldown: time.Hour}
+
+	// The message keeps getting 429s while the rest of its batch goes through: it must
+	// use its attempts with backoff and end up dead, not be re-claimed every tick.
+	for attempt := 1; attempt <= 3; attempt++ {
+		store.pending = []outbox.Event{
+			testEvent(1, "throttled", "ticket.created", attempt),
+			testEvent(int64(attempt+1), "ok", "ticket.created", 1),
+		}
+		if _, err := rl.tick(context.Background()); err != nil {
+			t.Fatalf("tick: %v", err)
+		}
+	}
+	if len(store.released) != 0 {
+		t.Fatalf("an isolated unavailable message must not be released, got %v", store.released)
+	}
+	if len(store.failed) != 2 || store.retryAt[0].Sub(testNow) != time.Second || len(store.dead) != 1 {
+		t.Fatalf("expected 2 retries with backoff then dead, got failed=%v retryAt=%v dead=%v", store.failed, store.retryAt, store.dead)
+	}
+	if len(store.sent) != 3 || rl.breaker.State() != outbox.BreakerClosed {
+		t.Fatalf("the sink is up: expected 3 sent and a closed breaker, got sent=%v %s", store.sent, rl.breaker.State())
+	}
+}
+
+func TestTickReleasesKeySkippedEvents(t *testing.T) {
+	for name, first := range map[string]error{
+		"message error": errors.New("broker said no"),
+		"unavailable":   &outbox.UnavailableError{Err: errors.New("timeout")},
+	} {
+		store := &fakeStore{pending: []outbox.Event{
+			testEvent(1, "a", "ticket.created", 1),
+			testEvent(2, "a", "ticket.created", 1),
+		}}
+		rl := newTestRelay(t, store, &memPublisher{}, &outbox.Router{DefaultTopic: "tickets.events"})
+		rl.breaker = &outbox.Breaker{Threshold: 1, Cooldown: time.Hour}
+		// What PoolPublisher returns once the first event of a key fails.
+		rl.pub = publishFunc(func(msgs []outbox.Message) []error {
+			return []error{first, fmt.Errorf("%w: %w", outbox.ErrKeySkipped, first)}
+		})
+
+		if _, err := rl.tick(context.Background()); err != nil {
+			t.Fatalf("%s: tick: %v", name, err)
+		}
+		if slices.Contains(store.failed, 2) || len(store.dead) != 0 || !slices.Contains(store.released, 2) {
+			t.Fatalf("%s: the skipped event must be released without an attempt, got failed=%v dead=%v released=%v",
+				name, store.failed, store.dead, store.released)
+		}
+		// The skipped event says nothing about the sink: the breaker follows the first one.
+		want := outbox.BreakerClosed
+		if outbox.IsUnavailable(first) {
+			want = outbox.BreakerOpen
+		}
+		if rl.breaker.State() != want {
+			t.Fatalf("%s: expected %s breaker, got %s", name, want, rl.breaker.State())
+		}
+	}
+}
+
+// openBreaker returns a breaker that is open with its cooldown already over, so the
+// next Allow is a half-open probe.
+func openBreaker() *outbox.Breaker {
+	now := testNow
+	b := &outbox.Breaker{Threshold: 1, Cooldown: time.Minute, Now: func() time.Time { return now }}
+	b.Failure()
+	now = now.Add(time.Hour)
+	return b
+}
+
+func TestTickResolvesEmptyProbe(t *testing.T) {
+	rl := newTestRelay(t, &fakeStore{}, &memPublisher{}, &outbox.Router{DefaultTopic: "tickets.events"})
+	rl.breaker = openBreaker()
+
+	if n, err := rl.tick(context.Background()); err != nil || n != 0 {
+		t.Fatalf("tick: n=%d err=%v", n, err)
+	}
+	if rl.breaker.State() != outbox.BreakerClosed {
+		t.Fatalf("an empty probe must close the breaker, got %s", rl.breaker.State())
+	}
+}
+
+func TestTickResolvesProbeOnNonTransportError(t *testing.T) {
+	store := &fakeStore{pending: []outbox.Event{testEvent(1, "a", "ticket.created", 1)}}
+	pub := &memPublisher{fail: map[string]bool{"tickets.events/a": true}}
+	rl := newTestRelay(t, store, pub, &outbox.Router{DefaultTopic: "tickets.events"})
+	rl.breaker = openBreaker()
+
+	if _, err := rl.tick(context.Background()); err != nil {
+		t.Fatalf("tick: %v", err)
+	}
+	if rl.breaker.State() != outbox.BreakerClosed || len(store.failed) != 1 {
+		t.Fatalf("the sink answered, expected closed breaker and a failed event, got %s failed=%v", rl.breaker.State(), store.failed)
+	}
+
+	rl.breaker = openBreaker()
+	store.pending = []outbox.Event{testEvent(2, "b", "ticket.created", 1)}
+	pub.down = true
+	if _, err := rl.tick(context.Background()); err != nil {
+		t.Fatalf("tick: %v", err)
+	}
+	if rl.breaker.State() != outbox.BreakerOpen {
+		t.Fatalf("an unavailable probe must reopen the breaker, got %s", rl.breaker.State())
+	}
+}
+
+func TestTickSchedulesRetryPerEventType(t *testing.T) {
+	store := &fakeStore{pending: []outbox.Event{
+		testEvent(1, "a", "ticket.created", 2),
+		testEvent(2, "b", "ticket.worklog_added", 2),
+	}}
+	pub := &memPublisher{fail: map[string]bool{"tickets.events/a": true, "tickets.events/b": true}}
+	rl := newTestRelay(t, store, pub, &outbox.Router{DefaultTopic: "tickets.events"})
+	rl.retry = retry.Policies{
+		Default: retry.Exponential{Base: time.Second, Max: time.Minute},
+		ByType:  map[string]retry.Policy{"ticket.worklog_added": retry.Fixed{Interval: 30 * time.Second}},
+	}
+
+	if _, err := rl.tick(context.Background()); err != nil {
+		t.Fatalf("tick: %v", err)
+	}
+	if len(store.retryAt) != 2 {
+		t.Fatalf("expected 2 rescheduled, got %v", store.failed)
+	}
+	if got := store.retryAt[0].Sub(testNow); got != 2*time.Second {
+		t.Fatalf("ticket.created: expected +2s, got %s", got)
+	}
+	if got := store.retryAt[1].Sub(testNow); got != 30*time.Second {
+		t.Fatalf("ticket.worklog_added: expected +30s, got %s", got)
+	}
+}
+
+func TestTickClaimsNothingAfterStop(t *testing.T) {
+	store := &fakeStore{pending: []outbox.Event{testEvent(1, "a", "ticket.created", 1)}}
+	rl := newTestRelay(t, store, &memPublisher{}, &outbox.Router{DefaultTopic: "tickets.events"})
+	stop, cancel := context.WithCancel(context.Background())
+	rl.stop = stop
+	cancel()
+
+	// The signal arrived while the tick was starting; its work ctx is still live.
+	if n, err := rl.tick(context.Background()); err != nil || n != 0 {
+		t.Fatalf("tick: n=%d err=%v", n, err)
+	}
+	if len(store.pending) != 1 || len(store.sent) != 0 {
+		t.Fatalf("expected no new claim after stop, pending=%d sent=%v", len(store.pending), store.sent)
+	}
+}
+
+func TestTickReleasesInterruptedBatchOnShutdown(t *testing.T) {
+	store := &fakeStore{pending: []outbox.Event{
+		testEvent(1, "a", "ticket.created", 3),
+		testEvent(2, "b", "ticket.created", 1),
+	}}
+	pub := &memPublisher{block: true}
+	rl := newTestRelay(t, store, pub, &outbox.Router{DefaultTopic: "tickets.events"})
+
+	// Drain timeout fires while the batch is being published.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if _, err := rl.tick(ctx); err != nil {
+		t.Fatalf("tick: %v", err)
+	}
+	if len(store.released) != 2 {
+		t.Fatalf("expected both events released, got %v", store.released)
+	}
+	// Event 1 is at MaxAttempts, yet must not be dead-lettered on shutdown.
+	if len(store.failed) != 0 || len(store.dead) != 0 || len(pub.msgs) != 0 {
+		t.Fatalf("expected no failed/dead/DLQ, got failed=%v dead=%v msgs=%d", store.failed, store.dead, len(pub.msgs))
+	}
+}
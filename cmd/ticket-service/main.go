@@ -4,8 +4,11 @@ import (
 	"context"
 	"log/slog"
 	"net/http"
+	"os"
 	"time"
 
+	"github.com/k1networth/servicedesk-lite/internal/outbox"
+	"github.com/k1networth/servicedesk-lite/internal/shared/blob"
 	"github.com/k1networth/servicedesk-lite/internal/shared/config"
 	"github.com/k1networth/servicedesk-lite/internal/shared/db"
 	"github.com/k1networth/servicedesk-lite/internal/shared/env"
@@ -40,7 +43,20 @@ func main() {
 			}
 		}()
 
-		store = ticket.NewPostgresStore(pg)
+		// Claim check: payloads above the threshold go to CLAIM_CHECK_DIR, shared with the consumers.
+		w := outbox.NewWriter()
+		if dir := env.String("CLAIM_CHECK_DIR", ""); dir != "" {
+			blobs, err := blob.NewFSStore(dir)
+			if err != nil {
+				log.Error("config_error", slog.String("err", err.Error()))
+				os.Exit(2)
+			}
+			w.Blobs = blobs
+			w.ClaimCheckThreshold = env.Int("CLAIM_CHECK_THRESHOLD", 256*1024)
+			log.Info("claim_check", slog.String("dir", dir), slog.Int("threshold", w.ClaimCheckThreshold))
+		}
+
+		store = ticket.NewPostgresStore(pg, w)
 		readyz = func(ctx context.Context) error { return pg.PingContext(ctx) }
 		log.Info("storage", slog.String("type", "postgres"))
 	} else {
@@ -0,0 +1,246 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrInvalidRequest is returned (wrapped) for admin requests that are rejected before
+// touching the database: no actor, an unsupported status or an unbounded filter.
+var ErrInvalidRequest = errors.New("outbox: invalid admin request")
+
+// Admin actions, as recorded in outbox_admin_audit.action.
+const (
+	AdminRequeue = "requeue"
+	AdminSkip    = "skip"
+	AdminPurge   = "purge"
+)
+
+const (
+	adminListLimit    = 100
+	adminListLimitMax = 1000
+)
+
+// Admin is the operator interface to the outbox (outbox-relay's /admin API and the
+// outbox-admin CLI): it lists rows and fixes them without hand-written SQL. Every change
+// is recorded in outbox_admin_audit by the same statement that makes it, together with
+// the ids of the affected rows, so the audit cannot miss or invent a change.
+type Admin struct {
+	db *sql.DB
+}
+
+func NewAdmin(db *sql.DB) *Admin { return &Admin{db: db} }
+
+// AdminFilter selects outbox rows. Empty fields match everything. Changes must be
+// narrowed by IDs, EventType, Aggregate or AggregateID unless All is set.
+type AdminFilter struct {
+	IDs         []int64 `json:"ids,omitempty"`
+	Status      string  `json:"status,omitempty"`
+	EventType   string  `json:"event_type,omitempty"`
+	Aggregate   string  `json:"aggregate,omitempty"`
+	AggregateID string  `json:"aggregate_id,omitempty"`
+	All         bool    `json:"all,omitempty"`
+	// Limit caps List (default 100, max 1000); changes are not limited.
+	Limit int `json:"limit,omitempty"`
+}
+
+func (f AdminFilter) bounded() bool {
+	return len(f.IDs) > 0 || f.EventType != "" || f.Aggregate != "" || f.AggregateID != ""
+}
+
+// where appends the filter's predicates (except Status) as "AND ..." to args.
+func (f AdminFilter) where(args []any) (string, []any) {
+	var b strings.Builder
+	add := func(cond string, v any) {
+		args = append(args, v)
+		b.WriteString("\n  AND " + fmt.Sprintf(cond, len(args)))
+	}
+	if len(f.IDs) > 0 {
+		add("id = ANY($%d::bigint[])", f.IDs)
+	}
+	if f.EventType != "" {
+		add("event_type = $%d", f.EventType)
+	}
+	if f.Aggregate != "" {
+		add("aggregate = $%d", f.Aggregate)
+	}
+	if f.AggregateID != "" {
+		add("aggregate_id = $%d", f.AggregateID)
+	}
+	return b.String(), args
+}
+
+// AdminAction identifies who makes a change and why.
+type AdminAction struct {
+	Actor  string
+	Reason string
+}
+
+// AdminRow is an outbox row as shown to operators.
+type AdminRow struct {
+	ID          int64           `json:"id"`
+	EventID     string          `json:"event_id"`
+	Aggregate   string          `json:"aggregate"`
+	AggregateID string          `json:"aggregate_id"`
+	EventType   string          `json:"event_type"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	Priority    int             `json:"priority"`
+	LastError   string          `json:"last_error,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	NextRetryAt time.Time       `json:"next_retry_at"`
+	SentAt      *time.Time      `json:"sent_at,omitempty"`
+	FailedAt    *time.Time      `json:"failed_at,omitempty"`
+	Payload     json.RawMessage `json:"payload"`
+}
+
+// List returns the newest rows matching f.
+func (a *Admin) List(ctx context.Context, f AdminFilter) ([]AdminRow, error) {
+	limit := f.Limit
+	if limit <= 0 {
+		limit = adminListLimit
+	}
+	limit = min(limit, adminListLimitMax)
+
+	args := []any{limit}
+	where, args := f.where(args)
+	if f.Status != "" {
+		args = append(args, f.Status)
+		where += fmt.Sprintf("\n  AND status = $%d", len(args))
+	}
+	q := `
+SELECT id, event_id, aggregate, aggregate_id, event_type, status, attempts, priority, COALESCE(last_error, ''),
+       created_at, next_retry_at, sent_at, failed_at, payload
+FROM outbox
+WHERE true` + where + `
+ORDER BY created_at DESC, id DESC
+LIMIT $1;
+`
+	rows, err := a.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	out := []AdminRow{}
+	for rows.Next() {
+		var r AdminRow
+		var sentAt, failedAt sql.NullTime
+		if err := rows.Scan(&r.ID, &r.EventID, &r.Aggregate, &r.AggregateID, &r.EventType, &r.Status, &r.Attempts, &r.Priority, &r.LastError,
+			&r.CreatedAt, &r.NextRetryAt, &sentAt, &failedAt, &r.Payload); err != nil {
+			return nil, err
+		}
+		if sentAt.Valid {
+			r.SentAt = &sentAt.Time
+		}
+		if failedAt.Valid {
+			r.FailedAt = &failedAt.Time
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// Counts returns the number of rows per status.
+func (a *Admin) Counts(ctx context.Context) (map[string]int64, error) {
+	rows, err := a.db.QueryContext(ctx, `SELECT status, COUNT(*) FROM outbox GROUP BY status;`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	out := map[string]int64{}
+	for rows.Next() {
+		var status string
+		var n int64
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, err
+		}
+		out[status] = n
+	}
+	return out, rows.Err()
+}
+
+// Requeue returns failed (dead) rows to pending with a fresh attempt budget; the relay
+// picks them up on its next tick.
+func (a *Admin) Requeue(ctx context.Context, f AdminFilter, act AdminAction) (int64, error) {
+	const stmt = `
+UPDATE outbox
+SET status = 'pending',
+    attempts = 0,
+    next_retry_at = now(),
+    processing_started_at = NULL,
+    failed_at = NULL,
+    updated_at = now()
+WHERE status = ANY($5::text[])%s
+RETURNING id`
+	return a.change(ctx, AdminRequeue, []string{"failed"}, stmt, f, act)
+}
+
+// Skip marks failed or pending rows as 'cancelled', so they are never published and no
+// longer block their aggregate under strict ordering. The rows stay for inspection
+// until retention or Purge removes them.
+func (a *Admin) Skip(ctx context.Context, f AdminFilter, act AdminAction) (int64, error) {
+	const stmt = `
+UPDATE outbox
+SET status = 'cancelled',
+    processing_started_at = NULL,
+    last_error = 'skipped by ' || $1 || COALESCE(': ' || NULLIF($3, ''), ''),
+    updated_at = now()
+WHERE status = ANY($5::text[])%s
+RETURNING id`
+	return a.change(ctx, AdminSkip, []string{"failed", "pending"}, stmt, f, act)
+}
+
+// Purge deletes rows that will not be published (failed, cancelled or sent).
+func (a *Admin) Purge(ctx context.Context, f AdminFilter, act AdminAction) (int64, error) {
+	const stmt = `
+DELETE FROM outbox
+WHERE status = ANY($5::text[])%s
+RETURNING id`
+	return a.change(ctx, AdminPurge, []string{"failed", "cancelled", "sent"}, stmt, f, act)
+}
+
+// change runs stmt (an UPDATE/DELETE ... RETURNING id over the rows in statuses that
+// match f) and inserts the audit record in the same statement. stmt's parameters:
+// $1 actor, $3 reason, $5 statuses, then the filter.
+func (a *Admin) change(ctx context.Context, action string, statuses []string, stmt string, f AdminFilter, act AdminAction) (int64, error) {
+	if strings.TrimSpace(act.Actor) == "" {
+		return 0, fmt.Errorf("%w: actor is required", ErrInvalidRequest)
+	}
+	if f.Status != "" {
+		if !slices.Contains(statuses, f.Status) {
+			return 0, fmt.Errorf("%w: cannot %s %s events (allowed: %s)", ErrInvalidRequest, action, f.Status, strings.Join(statuses, ", "))
+		}
+		statuses = []string{f.Status}
+	}
+	if !f.bounded() && !f.All {
+		return 0, fmt.Errorf("%w: %s needs ids, event_type, aggregate or aggregate_id (or all)", ErrInvalidRequest, action)
+	}
+
+	f.Limit = 0
+	filter, err := json.Marshal(f)
+	if err != nil {
+		return 0, err
+	}
+	where, args := f.where([]any{act.Actor, action, act.Reason, filter, statuses})
+	q := `
+WITH changed AS (` + fmt.Sprintf(stmt, where) + `
+)
+INSERT INTO outbox_admin_audit (actor, action, reason, filter, event_ids, affected)
+SELECT $1, $2, NULLIF($3, ''), $4::jsonb, COALESCE(array_agg(id ORDER BY id), '{}'), COUNT(*)
+FROM changed
+RETURNING affected;
+`
+	var n int64
+	if err := a.db.QueryRowContext(ctx, q, args...).Scan(&n); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
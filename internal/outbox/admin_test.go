@@ -0,0 +1,39 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/k1networth/servicedesk-lite/internal/outbox"
+)
+
+// The requests below are rejected before the database is used, hence the nil *sql.DB.
+func TestAdminRejectsUnsafeChanges(t *testing.T) {
+	admin := outbox.NewAdmin(nil)
+	ctx := context.Background()
+	alice := outbox.AdminAction{Actor: "alice"}
+
+	cases := map[string]func() (int64, error){
+		"no actor": func() (int64, error) {
+			return admin.Requeue(ctx, outbox.AdminFilter{IDs: []int64{1}}, outbox.AdminAction{})
+		},
+		"unbounded": func() (int64, error) {
+			return admin.Purge(ctx, outbox.AdminFilter{Status: "failed"}, alice)
+		},
+		"requeue sent": func() (int64, error) {
+			return admin.Requeue(ctx, outbox.AdminFilter{IDs: []int64{1}, Status: "sent"}, alice)
+		},
+		"purge pending": func() (int64, error) {
+			return admin.Purge(ctx, outbox.AdminFilter{EventType: "ticket.created", Status: "pending"}, alice)
+		},
+		"skip processing": func() (int64, error) {
+			return admin.Skip(ctx, outbox.AdminFilter{All: true, Status: "processing"}, alice)
+		},
+	}
+	for name, call := range cases {
+		if _, err := call(); !errors.Is(err, outbox.ErrInvalidRequest) {
+			t.Errorf("%s: expected ErrInvalidRequest, got %v", name, err)
+		}
+	}
+}
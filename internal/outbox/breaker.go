@@ -0,0 +1,124 @@
+package outbox
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// UnavailableError marks a failure of the sink itself (broker down, network, timeout)
+// rather than a problem with one message. The relay does not charge such failures to
+// the event's attempts; they feed the Breaker instead.
+type UnavailableError struct{ Err error }
+
+func (e *UnavailableError) Error() string { return "sink unavailable: " + e.Err.Error() }
+func (e *UnavailableError) Unwrap() error { return e.Err }
+
+func IsUnavailable(err error) bool {
+	var u *UnavailableError
+	return errors.As(err, &u)
+}
+
+type BreakerState int
+
+// Values are exported as the outbox_circuit_state gauge.
+const (
+	BreakerClosed BreakerState = iota
+	BreakerHalfOpen
+	BreakerOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerHalfOpen:
+		return "half_open"
+	case BreakerOpen:
+		return "open"
+	default:
+		return "closed"
+	}
+}
+
+// Breaker stops the relay from claiming events while the sink is down.
+//
+// After Threshold consecutive unavailable publishes it opens: nothing is claimed for
+// Cooldown. Then it lets a single-event probe through (half-open); a successful probe
+// closes it, a failed one opens it for another Cooldown.
+type Breaker struct {
+	Threshold int
+	Cooldown  time.Duration
+	// Now is the clock; nil means time.Now.
+	Now func() time.Time
+	// OnChange, if set, is called on every state transition (under the breaker's lock).
+	OnChange func(from, to BreakerState)
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+}
+
+// Allow reports whether a batch may be claimed now, and whether it must be a
+// single-event probe.
+func (b *Breaker) Allow() (ok, probe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.Cooldown {
+			return false, false
+		}
+		b.setLocked(BreakerHalfOpen)
+		return true, true
+	case BreakerHalfOpen:
+		return true, true
+	default:
+		return true, false
+	}
+}
+
+// Success records a publish that reached the sink.
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.setLocked(BreakerClosed)
+}
+
+// Failure records a publish that failed because the sink was unavailable.
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.Threshold {
+		b.openedAt = b.now()
+		b.setLocked(BreakerOpen)
+	}
+}
+
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+func (b *Breaker) setLocked(s BreakerState) {
+	if s == b.state {
+		return
+	}
+	from := b.state
+	b.state = s
+	if b.OnChange != nil {
+		b.OnChange(from, s)
+	}
+}
+
+func (b *Breaker) now() time.Time {
+	if b.Now != nil {
+		return b.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,43 @@
+package outbox_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/k1networth/servicedesk-lite/internal/outbox"
+)
+
+func TestBreakerOpensProbesAndCloses(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := &outbox.Breaker{Threshold: 2, Cooldown: 10 * time.Second, Now: func() time.Time { return now }}
+
+	b.Failure()
+	if ok, _ := b.Allow(); !ok {
+		t.Fatalf("expected closed after 1 failure")
+	}
+	b.Failure()
+	if ok, _ := b.Allow(); ok || b.State() != outbox.BreakerOpen {
+		t.Fatalf("expected open after 2 failures, state=%s", b.State())
+	}
+
+	now = now.Add(10 * time.Second)
+	if ok, probe := b.Allow(); !ok || !probe {
+		t.Fatalf("expected a probe after cooldown, ok=%v probe=%v", ok, probe)
+	}
+
+	// Failed probe: open again for a full cooldown.
+	b.Failure()
+	now = now.Add(5 * time.Second)
+	if ok, _ := b.Allow(); ok {
+		t.Fatalf("expected open after failed probe")
+	}
+
+	now = now.Add(5 * time.Second)
+	if ok, probe := b.Allow(); !ok || !probe {
+		t.Fatalf("expected second probe")
+	}
+	b.Success()
+	if ok, probe := b.Allow(); !ok || probe || b.State() != outbox.BreakerClosed {
+		t.Fatalf("expected closed after successful probe, state=%s", b.State())
+	}
+}
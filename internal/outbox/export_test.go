@@ -0,0 +1,14 @@
+package outbox
+
+import "context"
+
+// Beat runs one Sharder heartbeat over conn (see beat).
+func (s *Sharder) Beat(ctx context.Context, conn shardConn, owned map[int]bool) (bool, error) {
+	return s.beat(ctx, conn, owned)
+}
+
+// ListenerBackoff and Signal expose the Listener's reconnect delay and wake-up send.
+var (
+	ListenerBackoff = listenerBackoff
+	Signal          = signal
+)
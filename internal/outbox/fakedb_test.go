@@ -0,0 +1,144 @@
+package outbox_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+)
+
+// fakeDB is a scripted database/sql driver: it records every statement and answers
+// it with respond, so code that issues SQL can be tested without Postgres.
+type fakeDB struct {
+	respond func(query string, args []any) fakeResult
+
+	mu    sync.Mutex
+	stmts []fakeStmt
+}
+
+type fakeStmt struct {
+	query string
+	args  []any
+}
+
+// fakeResult answers one statement: rows for queries, affected for execs.
+type fakeResult struct {
+	cols     []string
+	rows     [][]driver.Value
+	affected int64
+	err      error
+}
+
+func newFakeDB(respond func(query string, args []any) fakeResult) (*fakeDB, *sql.DB) {
+	f := &fakeDB{respond: respond}
+	return f, sql.OpenDB(f)
+}
+
+// queries returns the recorded statements containing substr.
+func (f *fakeDB) queries(substr string) []fakeStmt {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []fakeStmt
+	for _, s := range f.stmts {
+		if strings.Contains(s.query, substr) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// index is the position of the first statement containing substr, or -1.
+func (f *fakeDB) index(substr string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, s := range f.stmts {
+		if strings.Contains(s.query, substr) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f *fakeDB) run(query string, named []driver.NamedValue) fakeResult {
+	args := make([]any, len(named))
+	for i, a := range named {
+		args[i] = a.Value
+	}
+	f.mu.Lock()
+	f.stmts = append(f.stmts, fakeStmt{query: query, args: args})
+	f.mu.Unlock()
+	if f.respond == nil {
+		return fakeResult{}
+	}
+	return f.respond(query, args)
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return fakeConn{f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return fakeDriver{f} }
+
+type fakeDriver struct{ f *fakeDB }
+
+func (d fakeDriver) Open(string) (driver.Conn, error) { return fakeConn(d), nil }
+
+type fakeConn struct{ f *fakeDB }
+
+func (c fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fakedb: prepared statements are not supported")
+}
+func (c fakeConn) Close() error              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+// CheckNamedValue accepts any argument (slices included), like pgx does.
+func (c fakeConn) CheckNamedValue(*driver.NamedValue) error { return nil }
+
+func (c fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	res := c.f.run(query, args)
+	if res.err != nil {
+		return nil, res.err
+	}
+	return driver.RowsAffected(res.affected), nil
+}
+
+func (c fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	res := c.f.run(query, args)
+	if res.err != nil {
+		return nil, res.err
+	}
+	return &fakeRows{cols: res.cols, rows: res.rows}, nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct {
+	cols []string
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	if r.cols == nil && len(r.rows) > 0 {
+		return make([]string, len(r.rows[0]))
+	}
+	return r.cols
+}
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// row is a single-row result.
+func row(vals ...driver.Value) fakeResult {
+	return fakeResult{cols: make([]string, len(vals)), rows: [][]driver.Value{vals}}
+}
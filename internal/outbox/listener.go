@@ -0,0 +1,100 @@
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// DefaultNotifyChannel matches the trigger in migrations/0010_outbox_notify.up.sql.
+const DefaultNotifyChannel = "outbox_new"
+
+// Listener holds a dedicated Postgres connection in LISTEN mode and signals
+// wake whenever new outbox rows are committed. It is only a latency
+// optimization: the relay keeps polling, so a lost notification (or a broken
+// listener connection) delays delivery until the next poll, nothing more.
+type Listener struct {
+	DatabaseURL string
+	// Channel must match the trigger's pg_notify; empty means DefaultNotifyChannel.
+	Channel string
+	Log     *slog.Logger
+	Metrics *Metrics
+}
+
+// Run blocks until ctx is cancelled, reconnecting with backoff on errors.
+// wake should be buffered (cap 1); sends never block and coalesce.
+func (l *Listener) Run(ctx context.Context, wake chan<- struct{}) {
+	channel := l.Channel
+	if channel == "" {
+		channel = DefaultNotifyChannel
+	}
+
+	failures := 0
+	for {
+		err := l.listen(ctx, channel, wake, func() { failures = 0 })
+		l.Metrics.ListenerConnected.Set(0)
+		if ctx.Err() != nil {
+			return
+		}
+
+		failures++
+		delay := listenerBackoff(failures)
+		l.Metrics.ListenerReconnectsTotal.Inc()
+		l.Log.Warn("outbox_listener_disconnected",
+			slog.String("channel", channel),
+			slog.Int("failures", failures),
+			slog.String("retry_in", delay.String()),
+			slog.String("err", err.Error()),
+		)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (l *Listener) listen(ctx context.Context, channel string, wake chan<- struct{}, onConnected func()) error {
+	conn, err := pgx.Connect(ctx, l.DatabaseURL)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close(context.Background()) }()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		return err
+	}
+
+	l.Metrics.ListenerConnected.Set(1)
+	l.Log.Info("outbox_listener_connected", slog.String("channel", channel))
+	onConnected()
+
+	// Rows inserted while we were disconnected produced no notification we could see.
+	signal(wake)
+
+	for {
+		if _, err := conn.WaitForNotification(ctx); err != nil {
+			return err
+		}
+		l.Metrics.NotifyWakeupsTotal.Inc()
+		signal(wake)
+	}
+}
+
+func signal(wake chan<- struct{}) {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+func listenerBackoff(failures int) time.Duration {
+	d := time.Duration(1<<uint(min(failures-1, 5))) * time.Second
+	if d > 30*time.Second {
+		d = 30 * time.Second
+	}
+	return d
+}
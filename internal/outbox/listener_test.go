@@ -0,0 +1,48 @@
+package outbox_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/k1networth/servicedesk-lite/internal/outbox"
+)
+
+func TestListenerBackoffDoublesUpToCap(t *testing.T) {
+	cases := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 16 * time.Second},
+		{6, 30 * time.Second},
+		{7, 30 * time.Second},
+		{1000, 30 * time.Second},
+	}
+	for _, tc := range cases {
+		if got := outbox.ListenerBackoff(tc.failures); got != tc.want {
+			t.Errorf("ListenerBackoff(%d) = %v, want %v", tc.failures, got, tc.want)
+		}
+	}
+}
+
+func TestSignalDoesNotBlockOnFullChannel(t *testing.T) {
+	wake := make(chan struct{}, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		outbox.Signal(wake) // fills the buffer
+		outbox.Signal(wake) // pending wake-up already queued: dropped
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("signal blocked on a full wake channel")
+	}
+	if len(wake) != 1 {
+		t.Fatalf("expected one queued wake-up, got %d", len(wake))
+	}
+}
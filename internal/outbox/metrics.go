@@ -10,35 +10,132 @@ type Metrics struct {
 	FailedTotal    *prometheus.CounterVec
 	DeadTotal      *prometheus.CounterVec
 	LagSeconds     prometheus.Gauge
+	// PriorityLagSeconds is LagSeconds per priority lane.
+	PriorityLagSeconds *prometheus.GaugeVec
+	// BlockedAggregates is only maintained in strict ordering mode.
+	BlockedAggregates prometheus.Gauge
+
+	ListenerConnected       prometheus.Gauge
+	ListenerReconnectsTotal prometheus.Counter
+	NotifyWakeupsTotal      prometheus.Counter
+
+	Partitions             prometheus.Gauge
+	PartitionsCreatedTotal prometheus.Counter
+	PartitionsRemovedTotal *prometheus.CounterVec
+
+	ShardsOwned          prometheus.Gauge
+	ShardRebalancesTotal *prometheus.CounterVec
+
+	// CircuitState is the publish circuit breaker state: 0 closed, 1 half-open, 2 open.
+	CircuitState      prometheus.Gauge
+	CircuitOpensTotal prometheus.Counter
+	ReleasedTotal     *prometheus.CounterVec
+
+	// Worker pool (OUTBOX_RELAY_WORKERS > 1): messages waiting for a worker, workers
+	// publishing right now, and per-worker busy time (rate() of it is utilization).
+	WorkerQueueDepth  prometheus.Gauge
+	WorkersBusy       prometheus.Gauge
+	WorkerBusySeconds *prometheus.CounterVec
+
+	// RouteInfo exposes the loaded routing table: 1 per (pattern, topic).
+	RouteInfo *prometheus.GaugeVec
 }
 
-func NewMetrics(reg prometheus.Registerer) *Metrics {
+func NewMetrics(reg prometheus.Registerer, router *Router) *Metrics {
 	m := &Metrics{
 		PublishedTotal: prometheus.NewCounterVec(
 			prometheus.CounterOpts{Name: "outbox_published_total", Help: "Published outbox events."},
-			[]string{"event_type"},
+			[]string{"event_type", "topic"},
 		),
 		FailedTotal: prometheus.NewCounterVec(
 			prometheus.CounterOpts{Name: "outbox_failed_total", Help: "Failed outbox publish attempts."},
-			[]string{"event_type"},
+			[]string{"event_type", "topic"},
 		),
 		DeadTotal: prometheus.NewCounterVec(
 			prometheus.CounterOpts{Name: "outbox_dead_total", Help: "Outbox events moved to dead/failed state."},
-			[]string{"event_type"},
+			[]string{"event_type", "topic"},
 		),
 		LagSeconds: prometheus.NewGauge(
 			prometheus.GaugeOpts{Name: "outbox_lag_seconds", Help: "Lag in seconds for oldest pending outbox event."},
 		),
+		PriorityLagSeconds: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{Name: "outbox_priority_lag_seconds", Help: "Lag in seconds for oldest pending outbox event, per priority."},
+			[]string{"priority"},
+		),
+		BlockedAggregates: prometheus.NewGauge(
+			prometheus.GaugeOpts{Name: "outbox_blocked_aggregates", Help: "Aggregates with events waiting behind a failed head-of-line event."},
+		),
+		ListenerConnected: prometheus.NewGauge(
+			prometheus.GaugeOpts{Name: "outbox_listener_connected", Help: "1 if the LISTEN connection is up, 0 otherwise."},
+		),
+		ListenerReconnectsTotal: prometheus.NewCounter(
+			prometheus.CounterOpts{Name: "outbox_listener_reconnects_total", Help: "LISTEN connection losses followed by a reconnect attempt."},
+		),
+		NotifyWakeupsTotal: prometheus.NewCounter(
+			prometheus.CounterOpts{Name: "outbox_notify_wakeups_total", Help: "NOTIFY messages received on the outbox channel."},
+		),
+		Partitions: prometheus.NewGauge(
+			prometheus.GaugeOpts{Name: "outbox_partitions", Help: "Attached outbox partitions (incl. default)."},
+		),
+		PartitionsCreatedTotal: prometheus.NewCounter(
+			prometheus.CounterOpts{Name: "outbox_partitions_created_total", Help: "Outbox partitions created by the maintenance routine."},
+		),
+		PartitionsRemovedTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{Name: "outbox_partitions_removed_total", Help: "Old fully-sent outbox partitions removed."},
+			[]string{"action"},
+		),
+		ShardsOwned: prometheus.NewGauge(
+			prometheus.GaugeOpts{Name: "outbox_shards_owned", Help: "Outbox shards currently owned by this replica."},
+		),
+		ShardRebalancesTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{Name: "outbox_shard_rebalances_total", Help: "Shard ownership changes of this replica."},
+			[]string{"action"},
+		),
+		CircuitState: prometheus.NewGauge(
+			prometheus.GaugeOpts{Name: "outbox_circuit_state", Help: "Publish circuit breaker state: 0 closed, 1 half-open, 2 open."},
+		),
+		CircuitOpensTotal: prometheus.NewCounter(
+			prometheus.CounterOpts{Name: "outbox_circuit_opens_total", Help: "Times the publish circuit breaker opened."},
+		),
+		ReleasedTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{Name: "outbox_released_total", Help: "Claimed events returned to pending without charging an attempt."},
+			[]string{"reason"},
+		),
+		WorkerQueueDepth: prometheus.NewGauge(
+			prometheus.GaugeOpts{Name: "outbox_worker_queue_depth", Help: "Messages queued for publishing workers."},
+		),
+		WorkersBusy: prometheus.NewGauge(
+			prometheus.GaugeOpts{Name: "outbox_workers_busy", Help: "Publishing workers currently busy."},
+		),
+		WorkerBusySeconds: prometheus.NewCounterVec(
+			prometheus.CounterOpts{Name: "outbox_worker_busy_seconds_total", Help: "Time each publishing worker spent publishing."},
+			[]string{"worker"},
+		),
+		RouteInfo: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{Name: "outbox_route_info", Help: "Event type routing rules (pattern \"*\" is the default topic)."},
+			[]string{"pattern", "topic"},
+		),
+	}
+	reg.MustRegister(m.PublishedTotal, m.FailedTotal, m.DeadTotal, m.LagSeconds, m.PriorityLagSeconds, m.BlockedAggregates,
+		m.ListenerConnected, m.ListenerReconnectsTotal, m.NotifyWakeupsTotal,
+		m.Partitions, m.PartitionsCreatedTotal, m.PartitionsRemovedTotal,
+		m.ShardsOwned, m.ShardRebalancesTotal,
+		m.CircuitState, m.CircuitOpensTotal, m.ReleasedTotal,
+		m.WorkerQueueDepth, m.WorkersBusy, m.WorkerBusySeconds, m.RouteInfo)
+
+	for _, rt := range router.Routes {
+		m.RouteInfo.WithLabelValues(rt.Pattern, rt.Topic).Set(1)
 	}
-	reg.MustRegister(m.PublishedTotal, m.FailedTotal, m.DeadTotal, m.LagSeconds)
+	m.RouteInfo.WithLabelValues("*", router.DefaultTopic).Set(1)
 
 	// Ensure time series exist even when counters are still zero.
 	// Without this, Grafana panels may show "No data" for rate/total queries
 	// until the first failure/dead event happens.
-	for _, et := range []string{events.EventTypeTicketCreated} {
-		m.PublishedTotal.WithLabelValues(et).Add(0)
-		m.FailedTotal.WithLabelValues(et).Add(0)
-		m.DeadTotal.WithLabelValues(et).Add(0)
+	for _, et := range []string{events.EventTypeTicketCreated, events.EventTypeTicketWorklogAdded} {
+		topic := router.Topic(et)
+		m.PublishedTotal.WithLabelValues(et, topic).Add(0)
+		m.FailedTotal.WithLabelValues(et, topic).Add(0)
+		m.DeadTotal.WithLabelValues(et, topic).Add(0)
 	}
 	return m
 }
@@ -0,0 +1,264 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// PartitionManager maintains daily partitions of the outbox table
+// (see migrations/0013_outbox_partitioned.up.sql):
+//   - pre-creates partitions for today and the next Premake days;
+//   - detaches partitions whose whole range is older than Retain and whose rows are
+//     all sent or cancelled, and drops them when Drop is set.
+//
+// A run holds a session-level advisory lock on one connection, so only one replica does
+// DDL at a time, and runs every step as its own statement: one partition that cannot be
+// created does not stop the others or retention. Partitions are detached with
+// DETACH ... CONCURRENTLY, which does not block writers of other partitions; that is not
+// allowed while a DEFAULT partition exists, then a plain DETACH is used under a short
+// lock_timeout. On a non-partitioned outbox table it does nothing.
+type PartitionManager struct {
+	DB       *sql.DB
+	Premake  int
+	Retain   time.Duration // 0 keeps all partitions
+	Drop     bool          // false: detach only, leave the table for archiving
+	Interval time.Duration
+	Log      *slog.Logger
+	Metrics  *Metrics
+	// Active, if set, gates each run: with sharding only the shard-0 owner maintains
+	// partitions (Sharder.Maintainer).
+	Active func() bool
+	// Now is the clock; nil means time.Now.
+	Now func() time.Time
+}
+
+// Run blocks until ctx is cancelled, calling Maintain every Interval.
+func (m *PartitionManager) Run(ctx context.Context) {
+	interval := m.Interval
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		if m.Active == nil || m.Active() {
+			if err := m.Maintain(ctx); err != nil && ctx.Err() == nil {
+				m.Log.Error("outbox_partitions_failed", slog.String("err", err.Error()))
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+type partition struct {
+	name          string
+	upper         sql.NullTime // NULL for DEFAULT / MAXVALUE
+	isDefault     bool
+	detachPending bool // an interrupted DETACH CONCURRENTLY
+}
+
+// detachLockTimeout bounds the wait for the ACCESS EXCLUSIVE lock of a plain DETACH.
+const detachLockTimeout = "5s"
+
+// Maintain runs one maintenance pass. Failed steps are logged, skipped and returned
+// together; the remaining steps still run.
+func (m *PartitionManager) Maintain(ctx context.Context) (err error) {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+
+	var partitioned bool
+	if err := conn.QueryRowContext(ctx, `SELECT relkind = 'p' FROM pg_class WHERE oid = 'outbox'::regclass;`).Scan(&partitioned); err != nil {
+		return err
+	}
+	if !partitioned {
+		return nil
+	}
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock(hashtext('outbox:partitions'));`).Scan(&locked); err != nil {
+		return err
+	}
+	if !locked {
+		return nil
+	}
+	defer func() {
+		uctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 2*time.Second)
+		defer cancel()
+		if _, uerr := conn.ExecContext(uctx, `SELECT pg_advisory_unlock(hashtext('outbox:partitions'));`); uerr != nil {
+			// A session lock must not go back to the pool with the connection.
+			_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+	}()
+
+	var errs []error
+	today := m.now().UTC().Truncate(24 * time.Hour)
+	for d := 0; d <= m.Premake; d++ {
+		lo := today.AddDate(0, 0, d)
+		created, err := createDayPartition(ctx, conn, lo)
+		if err != nil {
+			m.Log.Error("outbox_partition_create_failed", slog.String("day", lo.Format("2006-01-02")), slog.String("err", err.Error()))
+			errs = append(errs, err)
+			continue
+		}
+		if created {
+			m.Metrics.PartitionsCreatedTotal.Inc()
+			m.Log.Info("outbox_partition_created", slog.String("day", lo.Format("2006-01-02")))
+		}
+	}
+
+	parts, err := listPartitions(ctx, conn)
+	if err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+
+	if m.Retain > 0 {
+		hasDefault := false
+		for _, p := range parts {
+			hasDefault = hasDefault || p.isDefault
+		}
+		cutoff := m.now().Add(-m.Retain)
+		kept := parts[:0]
+		for _, p := range parts {
+			if !p.upper.Valid || p.upper.Time.After(cutoff) {
+				kept = append(kept, p)
+				continue
+			}
+			removed, err := m.removeIfSent(ctx, conn, p, hasDefault)
+			if err != nil {
+				m.Log.Error("outbox_partition_remove_failed", slog.String("partition", p.name), slog.String("err", err.Error()))
+				errs = append(errs, err)
+			}
+			if !removed {
+				kept = append(kept, p)
+			}
+		}
+		parts = kept
+	}
+
+	m.Metrics.Partitions.Set(float64(len(parts)))
+	return errors.Join(errs...)
+}
+
+func (m *PartitionManager) now() time.Time {
+	if m.Now != nil {
+		return m.Now()
+	}
+	return time.Now()
+}
+
+func createDayPartition(ctx context.Context, conn *sql.Conn, lo time.Time) (bool, error) {
+	name := "outbox_p" + lo.Format("20060102")
+
+	var exists bool
+	if err := conn.QueryRowContext(ctx, `SELECT to_regclass($1) IS NOT NULL;`, name).Scan(&exists); err != nil {
+		return false, err
+	}
+	if exists {
+		return false, nil
+	}
+
+	// Names and bounds are generated here, not user input.
+	q := fmt.Sprintf(`CREATE TABLE %s PARTITION OF outbox FOR VALUES FROM ('%s') TO ('%s');`,
+		name, lo.Format(time.RFC3339), lo.AddDate(0, 0, 1).Format(time.RFC3339))
+	if _, err := conn.ExecContext(ctx, q); err != nil {
+		return false, fmt.Errorf("create %s: %w", name, err)
+	}
+	return true, nil
+}
+
+func listPartitions(ctx context.Context, conn *sql.Conn) ([]partition, error) {
+	const q = `
+SELECT c.relname,
+       substring(pg_get_expr(c.relpartbound, c.oid) FROM 'TO \(''([^'']+)''\)')::timestamptz,
+       pg_get_expr(c.relpartbound, c.oid) = 'DEFAULT',
+       i.inhdetachpending
+FROM pg_inherits i
+JOIN pg_class c ON c.oid = i.inhrelid
+WHERE i.inhparent = 'outbox'::regclass
+ORDER BY 2 NULLS LAST;
+`
+	rows, err := conn.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var out []partition
+	for rows.Next() {
+		var p partition
+		if err := rows.Scan(&p.name, &p.upper, &p.isDefault, &p.detachPending); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// removeIfSent detaches (and drops) the partition if all its rows are sent or cancelled.
+// The check runs under the maintenance lock, right before the DETACH: rows of an old
+// partition can only leave the unsent states (admin requeue needs a failed row, which
+// would keep the partition), so the result cannot go stale in between.
+func (m *PartitionManager) removeIfSent(ctx context.Context, conn *sql.Conn, p partition, hasDefault bool) (bool, error) {
+	name := p.name
+	// name comes from pg_class; quote it anyway.
+	ident := `"` + name + `"`
+
+	var unsent bool
+	if err := conn.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM `+ident+` WHERE status NOT IN ('sent', 'cancelled'));`).Scan(&unsent); err != nil {
+		return false, err
+	}
+	if unsent {
+		return false, nil
+	}
+
+	switch {
+	case p.detachPending:
+		if _, err := conn.ExecContext(ctx, `ALTER TABLE outbox DETACH PARTITION `+ident+` FINALIZE;`); err != nil {
+			return false, fmt.Errorf("finalize detach %s: %w", name, err)
+		}
+	case hasDefault:
+		// DETACH CONCURRENTLY is not allowed next to a DEFAULT partition; fail fast
+		// instead of queueing every writer behind the ACCESS EXCLUSIVE lock.
+		if _, err := conn.ExecContext(ctx, `SET lock_timeout = '`+detachLockTimeout+`';`); err != nil {
+			return false, err
+		}
+		_, err := conn.ExecContext(ctx, `ALTER TABLE outbox DETACH PARTITION `+ident+`;`)
+		if _, rerr := conn.ExecContext(context.WithoutCancel(ctx), `RESET lock_timeout;`); rerr != nil && err == nil {
+			err = rerr
+		}
+		if err != nil {
+			return false, fmt.Errorf("detach %s: %w", name, err)
+		}
+	default:
+		// If interrupted, the partition stays "detach pending" and is finalized next run.
+		if _, err := conn.ExecContext(ctx, `ALTER TABLE outbox DETACH PARTITION `+ident+` CONCURRENTLY;`); err != nil {
+			return false, fmt.Errorf("detach %s: %w", name, err)
+		}
+	}
+
+	action := "detached"
+	if m.Drop {
+		if _, err := conn.ExecContext(ctx, `DROP TABLE `+ident+`;`); err != nil {
+			return true, fmt.Errorf("drop %s: %w", name, err)
+		}
+		action = "dropped"
+	}
+
+	m.Metrics.PartitionsRemovedTotal.WithLabelValues(action).Inc()
+	m.Log.Info("outbox_partition_removed", slog.String("partition", name), slog.String("action", action))
+	return true, nil
+}
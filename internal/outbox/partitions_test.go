@@ -0,0 +1,208 @@
+package outbox_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/k1networth/servicedesk-lite/internal/outbox"
+)
+
+var partitionsNow = time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+// partitionCatalog answers PartitionManager's queries like a partitioned outbox would.
+type partitionCatalog struct {
+	notPartitioned bool
+	notLocked      bool
+	existing       map[string]bool // to_regclass
+	failCreate     string
+	parts          []partitionRow
+	unsent         map[string]bool
+}
+
+type partitionRow struct {
+	name          string
+	upper         time.Time // zero: DEFAULT
+	detachPending bool
+}
+
+func (c *partitionCatalog) respond(q string, args []any) fakeResult {
+	switch {
+	case strings.Contains(q, "relkind"):
+		return row(!c.notPartitioned)
+	case strings.Contains(q, "pg_try_advisory_lock"):
+		return row(!c.notLocked)
+	case strings.Contains(q, "to_regclass"):
+		return row(c.existing[args[0].(string)])
+	case strings.Contains(q, "CREATE TABLE"):
+		if c.failCreate != "" && strings.Contains(q, c.failCreate) {
+			return fakeResult{err: errors.New("permission denied")}
+		}
+	case strings.Contains(q, "pg_inherits"):
+		res := fakeResult{cols: make([]string, 4)}
+		for _, p := range c.parts {
+			var upper driver.Value
+			if !p.upper.IsZero() {
+				upper = p.upper
+			}
+			res.rows = append(res.rows, []driver.Value{p.name, upper, p.upper.IsZero(), p.detachPending})
+		}
+		return res
+	case strings.Contains(q, "SELECT EXISTS"):
+		for name, unsent := range c.unsent {
+			if strings.Contains(q, `"`+name+`"`) {
+				return row(unsent)
+			}
+		}
+		return row(false)
+	}
+	return fakeResult{}
+}
+
+func newPartitionManager(t *testing.T, c *partitionCatalog, retain time.Duration, drop bool) (*fakeDB, *outbox.PartitionManager) {
+	t.Helper()
+	f, db := newFakeDB(c.respond)
+	t.Cleanup(func() { _ = db.Close() })
+	return f, &outbox.PartitionManager{
+		DB:      db,
+		Premake: 2,
+		Retain:  retain,
+		Drop:    drop,
+		Log:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Metrics: outbox.NewMetrics(prometheus.NewRegistry(), &outbox.Router{DefaultTopic: "tickets.events"}),
+		Now:     func() time.Time { return partitionsNow },
+	}
+}
+
+func day(s string) time.Time {
+	t, err := time.Parse("20060102", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestPartitionMaintainNeedsPartitionedTableAndLock(t *testing.T) {
+	for name, c := range map[string]*partitionCatalog{
+		"not partitioned":  {notPartitioned: true},
+		"locked elsewhere": {notLocked: true},
+	} {
+		f, m := newPartitionManager(t, c, time.Hour, true)
+		if err := m.Maintain(context.Background()); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if q := f.queries("CREATE TABLE"); len(q) > 0 {
+			t.Fatalf("%s: unexpected DDL: %v", name, q)
+		}
+		if q := f.queries("pg_advisory_unlock"); len(q) > 0 {
+			t.Fatalf("%s: unlocked a lock it does not hold", name)
+		}
+	}
+}
+
+func TestPartitionMaintainCreatesEachDaySeparately(t *testing.T) {
+	c := &partitionCatalog{
+		existing:   map[string]bool{"outbox_p20260310": true},
+		failCreate: "outbox_p20260311",
+		parts: []partitionRow{
+			{name: "outbox_p20260101", upper: day("20260102")},
+			{name: "outbox_p20260310", upper: day("20260311")},
+		},
+	}
+	f, m := newPartitionManager(t, c, 24*time.Hour, false)
+
+	err := m.Maintain(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "outbox_p20260311") {
+		t.Fatalf("expected the create error to be returned, got %v", err)
+	}
+	creates := f.queries("CREATE TABLE")
+	if len(creates) != 2 || !strings.Contains(creates[1].query, "outbox_p20260312 PARTITION OF outbox FOR VALUES FROM ('2026-03-12T00:00:00Z') TO ('2026-03-13T00:00:00Z')") {
+		t.Fatalf("expected creates for the 11th (failing) and the 12th, got %v", creates)
+	}
+	if len(f.queries(`DETACH PARTITION "outbox_p20260101"`)) != 1 {
+		t.Fatal("a failed create must not stop retention")
+	}
+	if len(f.queries("pg_advisory_unlock")) != 1 {
+		t.Fatal("expected the lock to be released")
+	}
+}
+
+func TestPartitionMaintainDetachesOnlySentPartitions(t *testing.T) {
+	c := &partitionCatalog{
+		existing: map[string]bool{"outbox_p20260310": true, "outbox_p20260311": true, "outbox_p20260312": true},
+		parts: []partitionRow{
+			{name: "outbox_p20260101", upper: day("20260102")},
+			{name: "outbox_p20260102", upper: day("20260103")},
+			{name: "outbox_p20260309", upper: day("20260310")},
+		},
+		unsent: map[string]bool{"outbox_p20260102": true},
+	}
+	f, m := newPartitionManager(t, c, 48*time.Hour, true)
+
+	if err := m.Maintain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	detach := f.queries("DETACH PARTITION")
+	if len(detach) != 1 || detach[0].query != `ALTER TABLE outbox DETACH PARTITION "outbox_p20260101" CONCURRENTLY;` {
+		t.Fatalf("expected one concurrent detach, got %v", detach)
+	}
+	if len(f.queries(`DROP TABLE "outbox_p20260101"`)) != 1 {
+		t.Fatal("expected the detached partition to be dropped")
+	}
+	if len(f.queries("lock_timeout")) != 0 {
+		t.Fatal("lock_timeout is only needed next to a default partition")
+	}
+	// The unsent check runs under the lock, and only for partitions past retention.
+	if lock, check := f.index("pg_try_advisory_lock"), f.index(`FROM "outbox_p20260102"`); check < lock {
+		t.Fatalf("unsent check (%d) must run after taking the lock (%d)", check, lock)
+	}
+	if len(f.queries(`FROM "outbox_p20260309"`)) != 0 {
+		t.Fatal("a partition inside the retention window must not be checked")
+	}
+}
+
+func TestPartitionMaintainDetachNextToDefaultPartition(t *testing.T) {
+	c := &partitionCatalog{
+		existing: map[string]bool{"outbox_p20260310": true, "outbox_p20260311": true, "outbox_p20260312": true},
+		parts: []partitionRow{
+			{name: "outbox_p20260101", upper: day("20260102")},
+			{name: "outbox_default"},
+		},
+	}
+	f, m := newPartitionManager(t, c, 48*time.Hour, false)
+
+	if err := m.Maintain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	set, detach, reset := f.index("SET lock_timeout"), f.index(`DETACH PARTITION "outbox_p20260101";`), f.index("RESET lock_timeout")
+	if set < 0 || detach < set || reset < detach {
+		t.Fatalf("expected SET lock_timeout, DETACH, RESET in order, got %d %d %d", set, detach, reset)
+	}
+	if len(f.queries("CONCURRENTLY")) != 0 || len(f.queries("DROP TABLE")) != 0 {
+		t.Fatal("unexpected concurrent detach or drop")
+	}
+}
+
+func TestPartitionMaintainFinalizesInterruptedDetach(t *testing.T) {
+	c := &partitionCatalog{
+		existing: map[string]bool{"outbox_p20260310": true, "outbox_p20260311": true, "outbox_p20260312": true},
+		parts:    []partitionRow{{name: "outbox_p20260101", upper: day("20260102"), detachPending: true}},
+	}
+	f, m := newPartitionManager(t, c, 48*time.Hour, false)
+
+	if err := m.Maintain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	detach := f.queries("DETACH PARTITION")
+	if len(detach) != 1 || !strings.HasSuffix(detach[0].query, "FINALIZE;") {
+		t.Fatalf("expected DETACH ... FINALIZE, got %v", detach)
+	}
+}
@@ -0,0 +1,206 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrKeySkipped is returned (wrapped, together with the error of the failed message)
+// for the messages PoolPublisher did not publish because an earlier message with the
+// same key failed in the same batch: publishing them would reorder the key.
+var ErrKeySkipped = errors.New("outbox: not published after an earlier message with the same key failed")
+
+// PoolPublisher publishes a batch concurrently on a fixed set of workers.
+//
+// Messages are assigned to workers by hash(key), so all events of one aggregate go to
+// the same worker and are published in batch order; different aggregates no longer
+// wait behind a slow partition. Once a message fails, the rest of its key in the batch
+// is returned as failed with ErrKeySkipped instead of being published.
+//
+// At most maxInFlight messages are in inner publisher calls at any time, across all
+// workers; each call carries an equal share of that budget (at least one message).
+// Publish still returns only when every message is done.
+type PoolPublisher struct {
+	inner   Publisher
+	step    int           // messages per inner call; 0: a worker's whole share
+	slots   chan struct{} // in-flight budget shared by all workers; nil: unlimited
+	acquire sync.Mutex    // one worker takes slots at a time, so partial holds cannot deadlock
+	met     *Metrics
+	queues  []chan poolJob
+	wg      sync.WaitGroup
+}
+
+type poolJob struct {
+	ctx  context.Context
+	msgs []Message
+	idx  []int   // positions in the caller's batch
+	errs []error // caller's result slice; each worker writes only its own idx
+	done *sync.WaitGroup
+}
+
+// NewPoolPublisher starts workers goroutines in front of inner. maxInFlight <= 0 means
+// no limit: a worker passes its whole share of a batch in one call.
+func NewPoolPublisher(inner Publisher, workers, maxInFlight int, met *Metrics) *PoolPublisher {
+	if workers < 1 {
+		workers = 1
+	}
+	p := &PoolPublisher{inner: inner, met: met, queues: make([]chan poolJob, workers)}
+	if maxInFlight > 0 {
+		p.step = max(1, maxInFlight/workers)
+		p.slots = make(chan struct{}, maxInFlight)
+	}
+	for i := range p.queues {
+		p.queues[i] = make(chan poolJob, 1)
+		met.WorkerBusySeconds.WithLabelValues(strconv.Itoa(i)).Add(0)
+		p.wg.Add(1)
+		go p.work(i)
+	}
+	return p
+}
+
+func (p *PoolPublisher) Publish(ctx context.Context, msgs []Message) []error {
+	errs := make([]error, len(msgs))
+
+	shares := make([][]int, len(p.queues))
+	for i, m := range msgs {
+		w := workerFor(m.Key, len(p.queues))
+		shares[w] = append(shares[w], i)
+	}
+
+	var done sync.WaitGroup
+	for w, idx := range shares {
+		if len(idx) == 0 {
+			continue
+		}
+		job := poolJob{ctx: ctx, msgs: make([]Message, len(idx)), idx: idx, errs: errs, done: &done}
+		for j, i := range idx {
+			job.msgs[j] = msgs[i]
+		}
+
+		done.Add(1)
+		p.met.WorkerQueueDepth.Add(float64(len(idx)))
+		select {
+		case p.queues[w] <- job:
+		case <-ctx.Done():
+			p.met.WorkerQueueDepth.Sub(float64(len(idx)))
+			for _, i := range idx {
+				errs[i] = ctx.Err()
+			}
+			done.Done()
+		}
+	}
+	done.Wait()
+	return errs
+}
+
+func (p *PoolPublisher) work(n int) {
+	defer p.wg.Done()
+	busy := p.met.WorkerBusySeconds.WithLabelValues(strconv.Itoa(n))
+
+	for job := range p.queues[n] {
+		p.met.WorkerQueueDepth.Sub(float64(len(job.msgs)))
+		p.met.WorkersBusy.Inc()
+		start := time.Now()
+
+		p.publish(job)
+
+		busy.Add(time.Since(start).Seconds())
+		p.met.WorkersBusy.Dec()
+		job.done.Done()
+	}
+}
+
+// publish sends one worker's share in calls of at most step messages, skipping the
+// keys that already failed.
+func (p *PoolPublisher) publish(job poolJob) {
+	step := p.step
+	if step <= 0 {
+		step = len(job.msgs)
+	}
+	failed := map[string]error{}
+	chunk := make([]int, 0, step)
+	batch := make([]Message, 0, step)
+
+	for lo := 0; lo < len(job.msgs); {
+		chunk, batch = chunk[:0], batch[:0]
+		for ; lo < len(job.msgs) && len(chunk) < step; lo++ {
+			m := job.msgs[lo]
+			if err, ok := failed[string(m.Key)]; ok {
+				job.errs[job.idx[lo]] = fmt.Errorf("%w: %w", ErrKeySkipped, err)
+				continue
+			}
+			chunk = append(chunk, lo)
+			batch = append(batch, m)
+		}
+		if len(chunk) == 0 {
+			continue
+		}
+
+		var errs []error
+		if err := p.take(job.ctx, len(chunk)); err != nil {
+			errs = make([]error, len(chunk))
+			for j := range errs {
+				errs[j] = err
+			}
+		} else {
+			errs = p.inner.Publish(job.ctx, batch)
+			p.give(len(chunk))
+		}
+		for j, err := range errs {
+			job.errs[job.idx[chunk[j]]] = err
+			if key := string(batch[j].Key); err != nil && failed[key] == nil {
+				failed[key] = err
+			}
+		}
+	}
+}
+
+// take acquires n slots of the in-flight budget (n never exceeds it).
+func (p *PoolPublisher) take(ctx context.Context, n int) error {
+	if p.slots == nil {
+		return nil
+	}
+	p.acquire.Lock()
+	defer p.acquire.Unlock()
+	for i := range n {
+		select {
+		case p.slots <- struct{}{}:
+		case <-ctx.Done():
+			p.give(i)
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (p *PoolPublisher) give(n int) {
+	if p.slots == nil {
+		return
+	}
+	for range n {
+		<-p.slots
+	}
+}
+
+// Close stops the workers (after queued jobs finish) and closes the inner publisher.
+func (p *PoolPublisher) Close() error {
+	for _, q := range p.queues {
+		close(q)
+	}
+	p.wg.Wait()
+	return p.inner.Close()
+}
+
+func workerFor(key []byte, workers int) int {
+	if workers == 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	_, _ = h.Write(key)
+	return int(h.Sum32() % uint32(workers))
+}
@@ -0,0 +1,154 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/k1networth/servicedesk-lite/internal/outbox"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// recordingPublisher remembers the order in which each key was published, the largest
+// call it received and the peak number of concurrent calls and messages; it fails
+// messages listed in fail as "key" or "key/value".
+type recordingPublisher struct {
+	mu       sync.Mutex
+	byKey    map[string][]string
+	maxCall  int
+	active   int
+	peak     int
+	inFlight int
+	peakMsgs int
+	fail     map[string]bool
+}
+
+func (p *recordingPublisher) Publish(_ context.Context, msgs []outbox.Message) []error {
+	p.mu.Lock()
+	p.active++
+	p.peak = max(p.peak, p.active)
+	p.maxCall = max(p.maxCall, len(msgs))
+	p.inFlight += len(msgs)
+	p.peakMsgs = max(p.peakMsgs, p.inFlight)
+	p.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	errs := make([]error, len(msgs))
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.active--
+	p.inFlight -= len(msgs)
+	for i, m := range msgs {
+		if p.fail[string(m.Key)] || p.fail[string(m.Key)+"/"+string(m.Value)] {
+			errs[i] = errors.New("rejected")
+			continue
+		}
+		p.byKey[string(m.Key)] = append(p.byKey[string(m.Key)], string(m.Value))
+	}
+	return errs
+}
+
+func (p *recordingPublisher) Close() error { return nil }
+
+func TestPoolPublisherKeepsPerKeyOrder(t *testing.T) {
+	inner := &recordingPublisher{byKey: map[string][]string{}, fail: map[string]bool{"k3": true}}
+	met := outbox.NewMetrics(prometheus.NewRegistry(), &outbox.Router{DefaultTopic: "tickets.events"})
+	p := outbox.NewPoolPublisher(inner, 4, 8, met)
+	defer func() { _ = p.Close() }()
+
+	msgs := testBatch(8, 5)
+	errs := p.Publish(context.Background(), msgs)
+
+	for i, m := range msgs {
+		if failed := errs[i] != nil; failed != (string(m.Key) == "k3") {
+			t.Fatalf("msg %d (%s): unexpected err %v", i, m.Key, errs[i])
+		}
+	}
+	for k, seqs := range inner.byKey {
+		for i, v := range seqs {
+			if v != strconv.Itoa(i) {
+				t.Fatalf("key %s published out of order: %v", k, seqs)
+			}
+		}
+	}
+	if inner.maxCall > 2 {
+		t.Fatalf("expected calls of at most 8/4 messages, got %d", inner.maxCall)
+	}
+	if inner.peakMsgs > 8 {
+		t.Fatalf("expected at most 8 messages in flight, got %d", inner.peakMsgs)
+	}
+	if inner.peak < 2 {
+		t.Fatalf("expected concurrent publishing, peak=%d", inner.peak)
+	}
+}
+
+func testBatch(keys, seqs int) []outbox.Message {
+	var msgs []outbox.Message
+	for seq := range seqs {
+		for k := range keys {
+			msgs = append(msgs, outbox.Message{
+				Topic: "tickets.events",
+				Key:   []byte("k" + strconv.Itoa(k)),
+				Value: []byte(strconv.Itoa(seq)),
+			})
+		}
+	}
+	return msgs
+}
+
+func TestPoolPublisherSharesInFlightBudget(t *testing.T) {
+	inner := &recordingPublisher{byKey: map[string][]string{}}
+	met := outbox.NewMetrics(prometheus.NewRegistry(), &outbox.Router{DefaultTopic: "tickets.events"})
+	p := outbox.NewPoolPublisher(inner, 4, 3, met)
+	defer func() { _ = p.Close() }()
+
+	for i, err := range p.Publish(context.Background(), testBatch(8, 3)) {
+		if err != nil {
+			t.Fatalf("msg %d: %v", i, err)
+		}
+	}
+	if inner.peakMsgs > 3 {
+		t.Fatalf("4 workers must share the budget of 3, got %d messages in flight", inner.peakMsgs)
+	}
+	if inner.peak < 2 {
+		t.Fatalf("expected concurrent publishing, peak=%d", inner.peak)
+	}
+}
+
+func TestPoolPublisherStopsKeyAfterFailure(t *testing.T) {
+	inner := &recordingPublisher{byKey: map[string][]string{}, fail: map[string]bool{"k1/1": true}}
+	met := outbox.NewMetrics(prometheus.NewRegistry(), &outbox.Router{DefaultTopic: "tickets.events"})
+	p := outbox.NewPoolPublisher(inner, 2, 1, met)
+	defer func() { _ = p.Close() }()
+
+	msgs := testBatch(2, 4)
+	errs := p.Publish(context.Background(), msgs)
+
+	for i, m := range msgs {
+		key, seq := string(m.Key), string(m.Value)
+		switch {
+		case key == "k0" || seq == "0":
+			if errs[i] != nil {
+				t.Fatalf("%s/%s: unexpected err %v", key, seq, errs[i])
+			}
+		case seq == "1":
+			if errs[i] == nil || errors.Is(errs[i], outbox.ErrKeySkipped) {
+				t.Fatalf("%s/%s: expected the publish error, got %v", key, seq, errs[i])
+			}
+		default:
+			if !errors.Is(errs[i], outbox.ErrKeySkipped) {
+				t.Fatalf("%s/%s: expected ErrKeySkipped, got %v", key, seq, errs[i])
+			}
+		}
+	}
+	if got := inner.byKey["k1"]; len(got) != 1 {
+		t.Fatalf("k1 must not be published past the failure, got %v", got)
+	}
+	if got := inner.byKey["k0"]; len(got) != 4 {
+		t.Fatalf("other keys must not be affected, got %v", got)
+	}
+}
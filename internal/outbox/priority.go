@@ -0,0 +1,29 @@
+package outbox
+
+import "strconv"
+
+// Event priorities (outbox.priority). Higher is claimed first; within a priority events
+// go oldest first. Bulk work (backfills, re-emits) should use PriorityLow so real-time
+// events do not queue behind it.
+const (
+	PriorityLow    = 0
+	PriorityNormal = 1
+	PriorityHigh   = 2
+)
+
+// Priorities lists all priorities, lowest first.
+var Priorities = []int{PriorityLow, PriorityNormal, PriorityHigh}
+
+// PriorityName is the metrics label of p.
+func PriorityName(p int) string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	default:
+		return strconv.Itoa(p)
+	}
+}
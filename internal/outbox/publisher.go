@@ -0,0 +1,48 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+)
+
+// Message is one serialized event addressed to a topic (Kafka topic, NATS subject, ...).
+type Message struct {
+	Topic   string
+	Key     []byte
+	Value   []byte
+	Headers []Header
+}
+
+// Header is a transport header (Kafka record header, HTTP header, NATS header).
+type Header struct {
+	Key   string
+	Value string
+}
+
+// Publisher delivers messages to a sink. Publish returns one error per message, in
+// the same order (nil means delivered); it must not return a shorter slice.
+type Publisher interface {
+	Publish(ctx context.Context, msgs []Message) []error
+	Close() error
+}
+
+// Sink names accepted by OUTBOX_RELAY_SINK.
+const (
+	SinkKafka   = "kafka"
+	SinkWebhook = "webhook"
+	SinkNATS    = "nats"
+	SinkFile    = "file"
+)
+
+// ErrUnknownTopic is returned for messages addressed to a topic the publisher was not set up for.
+type ErrUnknownTopic string
+
+func (e ErrUnknownTopic) Error() string { return fmt.Sprintf("unknown topic %q", string(e)) }
+
+// fill sets every entry of errs to err.
+func fill(errs []error, err error) []error {
+	for i := range errs {
+		errs[i] = err
+	}
+	return errs
+}
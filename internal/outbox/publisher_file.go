@@ -0,0 +1,72 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+// FilePublisher appends messages as NDJSON lines ({"topic","key","headers","value"}) to a file or
+// stdout. Meant for local debugging and for running without a broker.
+type FilePublisher struct {
+	mu    sync.Mutex
+	w     io.Writer
+	close func() error
+}
+
+// NewFilePublisher opens path for appending; "" or "-" means stdout.
+func NewFilePublisher(path string) (*FilePublisher, error) {
+	if path == "" || path == "-" {
+		return NewWriterPublisher(os.Stdout), nil
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &FilePublisher{w: f, close: f.Close}, nil
+}
+
+// NewWriterPublisher writes to w and never closes it.
+func NewWriterPublisher(w io.Writer) *FilePublisher {
+	return &FilePublisher{w: w, close: func() error { return nil }}
+}
+
+type fileRecord struct {
+	Topic   string            `json:"topic"`
+	Key     string            `json:"key"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Value   any               `json:"value"`
+}
+
+func (p *FilePublisher) Publish(ctx context.Context, msgs []Message) []error {
+	errs := make([]error, len(msgs))
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	bw := bufio.NewWriter(p.w)
+	enc := json.NewEncoder(bw)
+	for i, m := range msgs {
+		var v any = string(m.Value)
+		if json.Valid(m.Value) {
+			v = json.RawMessage(m.Value)
+		}
+		var hs map[string]string
+		if len(m.Headers) > 0 {
+			hs = make(map[string]string, len(m.Headers))
+			for _, h := range m.Headers {
+				hs[h.Key] = h.Value
+			}
+		}
+		errs[i] = enc.Encode(fileRecord{Topic: m.Topic, Key: string(m.Key), Headers: hs, Value: v})
+	}
+	if err := bw.Flush(); err != nil {
+		return fill(errs, err)
+	}
+	return errs
+}
+
+func (p *FilePublisher) Close() error { return p.close() }
@@ -0,0 +1,76 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/k1networth/servicedesk-lite/internal/shared/kafkax"
+)
+
+// KafkaPublisher keeps one kafkax.Producer per topic and sends each topic's share of a
+// batch with a single ProduceBatch call.
+type KafkaPublisher struct {
+	producers map[string]*kafkax.Producer
+	timeout   time.Duration
+}
+
+// NewKafkaPublisher creates producers for topics using cfg as a template (cfg.Topic is ignored).
+func NewKafkaPublisher(cfg kafkax.ProducerConfig, topics []string) *KafkaPublisher {
+	p := &KafkaPublisher{producers: make(map[string]*kafkax.Producer, len(topics)), timeout: cfg.WriteTimeout}
+	for _, t := range topics {
+		c := cfg
+		c.Topic = t
+		p.producers[t] = kafkax.NewProducer(c)
+	}
+	return p
+}
+
+func (p *KafkaPublisher) Publish(ctx context.Context, msgs []Message) []error {
+	errs := make([]error, len(msgs))
+
+	byTopic := make(map[string][]int) // topic -> indices into msgs
+	for i, m := range msgs {
+		byTopic[m.Topic] = append(byTopic[m.Topic], i)
+	}
+
+	for topic, idx := range byTopic {
+		prod, ok := p.producers[topic]
+		if !ok {
+			for _, i := range idx {
+				errs[i] = ErrUnknownTopic(topic)
+			}
+			continue
+		}
+		sub := make([]kafkax.Message, len(idx))
+		for j, i := range idx {
+			sub[j] = kafkax.Message{Key: msgs[i].Key, Value: msgs[i].Value, Headers: kafkaHeaders(msgs[i].Headers)}
+		}
+		for j, err := range prod.ProduceBatch(ctx, sub, p.timeout) {
+			if kafkax.IsBrokerError(err) {
+				err = &UnavailableError{Err: err}
+			}
+			errs[idx[j]] = err
+		}
+	}
+	return errs
+}
+
+func (p *KafkaPublisher) Close() error {
+	var errs []error
+	for _, prod := range p.producers {
+		errs = append(errs, prod.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func kafkaHeaders(hs []Header) []kafkax.Header {
+	if len(hs) == 0 {
+		return nil
+	}
+	out := make([]kafkax.Header, len(hs))
+	for i, h := range hs {
+		out[i] = kafkax.Header{Key: h.Key, Value: []byte(h.Value)}
+	}
+	return out
+}
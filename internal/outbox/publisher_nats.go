@@ -0,0 +1,298 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NATSPublisher speaks the core NATS text protocol (PUB + PING/PONG) over plain TCP,
+// so it works against nats-server and compatible brokers without a client library.
+// The topic is used as the subject; the key is not transmitted (core NATS has no keys).
+// Headers go out with HPUB when the server announces header support, otherwise they are dropped.
+//
+// Core NATS has no acks: every PUB is followed by a PING, and a message counts as
+// delivered once the server answers that PING, i.e. after it has processed the PUB.
+// Errors are attributed per message: an invalid subject or a payload over the server's
+// max_payload is rejected before sending, and a "-ERR" caused by a message (payload,
+// subject or permissions violation) fails only that message; the rest of the batch
+// goes on over a fresh connection. I/O errors, timeouts and other server errors fail
+// the remaining messages as unavailable and drop the connection; the next Publish
+// reconnects.
+type NATSPublisher struct {
+	addr    string
+	user    string
+	pass    string
+	timeout time.Duration
+
+	mu         sync.Mutex
+	conn       net.Conn
+	r          *bufio.Reader
+	headers    bool  // server INFO had "headers": true
+	maxPayload int64 // server INFO max_payload; 0 if not announced
+}
+
+// natsServerError is a "-ERR" line from the server.
+type natsServerError struct{ msg string }
+
+func (e *natsServerError) Error() string { return "server error: " + e.msg }
+
+// messageFault reports whether the server rejected the message itself rather than the
+// connection (e.g. 'Authorization Violation' or 'Stale Connection').
+func (e *natsServerError) messageFault() bool {
+	m := strings.ToLower(e.msg)
+	return strings.Contains(m, "payload") || strings.Contains(m, "subject") || strings.Contains(m, "permissions violation")
+}
+
+// NewNATSPublisher parses rawURL (nats://[user:pass@]host:port). The connection is opened lazily.
+func NewNATSPublisher(rawURL string, timeout time.Duration) (*NATSPublisher, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("nats url: %w", err)
+	}
+	if u.Scheme != "nats" || u.Host == "" {
+		return nil, fmt.Errorf("nats url: want nats://host:port, got %q", rawURL)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	p := &NATSPublisher{addr: addr, timeout: timeout}
+	if u.User != nil {
+		p.user = u.User.Username()
+		p.pass, _ = u.User.Password()
+	}
+	return p, nil
+}
+
+func (p *NATSPublisher) Publish(ctx context.Context, msgs []Message) []error {
+	errs := make([]error, len(msgs))
+	if len(msgs) == 0 {
+		return errs
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	todo := make([]int, 0, len(msgs))
+	for i := range msgs {
+		todo = append(todo, i)
+	}
+	for len(todo) > 0 {
+		if p.conn == nil {
+			if err := p.connectLocked(ctx); err != nil {
+				return fillIdx(errs, todo, &UnavailableError{Err: fmt.Errorf("nats: %w", err)})
+			}
+		}
+
+		// Messages the server would reject never go on the wire.
+		send := todo[:0:0]
+		for _, i := range todo {
+			if err := p.checkLocked(msgs[i]); err != nil {
+				errs[i] = fmt.Errorf("nats: %w", err)
+				continue
+			}
+			send = append(send, i)
+		}
+
+		n, err := p.sendLocked(ctx, msgs, send)
+		if err == nil {
+			return errs
+		}
+		p.dropLocked()
+		todo = send[n:]
+		var serr *natsServerError
+		if !errors.As(err, &serr) || !serr.messageFault() {
+			return fillIdx(errs, todo, &UnavailableError{Err: fmt.Errorf("nats: %w", err)})
+		}
+		// The server may close the connection after a -ERR; reconnect for the rest.
+		errs[todo[0]] = fmt.Errorf("nats: %w", err)
+		todo = todo[1:]
+	}
+	return errs
+}
+
+func fillIdx(errs []error, idx []int, err error) []error {
+	for _, i := range idx {
+		errs[i] = err
+	}
+	return errs
+}
+
+// checkLocked validates m against the publish rules and the server's limits.
+func (p *NATSPublisher) checkLocked(m Message) error {
+	if !validNATSSubject(m.Topic) {
+		return fmt.Errorf("invalid subject %q", m.Topic)
+	}
+	size := len(m.Value)
+	if p.headers && len(m.Headers) > 0 {
+		size += len(natsHeaderBlock(m.Headers))
+	}
+	if p.maxPayload > 0 && int64(size) > p.maxPayload {
+		return fmt.Errorf("payload of %d bytes exceeds max_payload %d", size, p.maxPayload)
+	}
+	return nil
+}
+
+// validNATSSubject accepts dot-separated non-empty tokens without whitespace or wildcards.
+func validNATSSubject(s string) bool {
+	if s == "" || strings.ContainsAny(s, " \t\r\n*>") {
+		return false
+	}
+	for _, tok := range strings.Split(s, ".") {
+		if tok == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// sendLocked pipelines PUB + PING for each message of idx and returns how many of them
+// the server confirmed before the first error.
+func (p *NATSPublisher) sendLocked(ctx context.Context, msgs []Message, idx []int) (int, error) {
+	if len(idx) == 0 {
+		return 0, nil
+	}
+	deadline := time.Now().Add(p.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := p.conn.SetDeadline(deadline); err != nil {
+		return 0, err
+	}
+
+	var b strings.Builder
+	for _, i := range idx {
+		m := msgs[i]
+		if p.headers && len(m.Headers) > 0 {
+			hdr := natsHeaderBlock(m.Headers)
+			b.WriteString("HPUB ")
+			b.WriteString(m.Topic)
+			b.WriteByte(' ')
+			b.WriteString(strconv.Itoa(len(hdr)))
+			b.WriteByte(' ')
+			b.WriteString(strconv.Itoa(len(hdr) + len(m.Value)))
+			b.WriteString("\r\n")
+			b.WriteString(hdr)
+		} else {
+			b.WriteString("PUB ")
+			b.WriteString(m.Topic)
+			b.WriteByte(' ')
+			b.WriteString(strconv.Itoa(len(m.Value)))
+			b.WriteString("\r\n")
+		}
+		b.Write(m.Value)
+		b.WriteString("\r\nPING\r\n")
+	}
+	if _, err := p.conn.Write([]byte(b.String())); err != nil {
+		return 0, err
+	}
+
+	for n := range idx {
+		if err := p.waitPong(); err != nil {
+			return n, err
+		}
+	}
+	return len(idx), nil
+}
+
+// waitPong reads server lines until PONG, answering server PINGs on the way.
+func (p *NATSPublisher) waitPong() error {
+	for {
+		line, err := p.r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := p.conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return &natsServerError{msg: strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "-ERR")), "'")}
+		default:
+			// +OK, INFO updates: nothing to do.
+		}
+	}
+}
+
+func (p *NATSPublisher) connectLocked(ctx context.Context) error {
+	d := net.Dialer{Timeout: p.timeout}
+	conn, err := d.DialContext(ctx, "tcp", p.addr)
+	if err != nil {
+		return err
+	}
+	_ = conn.SetDeadline(time.Now().Add(p.timeout))
+	r := bufio.NewReader(conn)
+
+	info, err := r.ReadString('\n')
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	if !strings.HasPrefix(info, "INFO ") {
+		_ = conn.Close()
+		return fmt.Errorf("unexpected greeting %q", strings.TrimSpace(info))
+	}
+	var server struct {
+		Headers    bool  `json:"headers"`
+		MaxPayload int64 `json:"max_payload"`
+	}
+	_ = json.Unmarshal([]byte(strings.TrimPrefix(info, "INFO ")), &server)
+
+	opts := map[string]any{"verbose": false, "pedantic": false, "name": "outbox-relay", "lang": "go"}
+	if p.user != "" {
+		opts["user"] = p.user
+		opts["pass"] = p.pass
+	}
+	b, _ := json.Marshal(opts)
+	if _, err := conn.Write([]byte("CONNECT " + string(b) + "\r\n")); err != nil {
+		_ = conn.Close()
+		return err
+	}
+
+	p.conn, p.r, p.headers, p.maxPayload = conn, r, server.Headers, server.MaxPayload
+	return nil
+}
+
+// natsHeaderBlock renders headers as "NATS/1.0\r\nKey: Value\r\n...\r\n".
+func natsHeaderBlock(hs []Header) string {
+	var b strings.Builder
+	b.WriteString("NATS/1.0\r\n")
+	for _, h := range hs {
+		b.WriteString(h.Key)
+		b.WriteString(": ")
+		b.WriteString(strings.NewReplacer("\r", "", "\n", "").Replace(h.Value))
+		b.WriteString("\r\n")
+	}
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+func (p *NATSPublisher) dropLocked() {
+	if p.conn != nil {
+		_ = p.conn.Close()
+	}
+	p.conn, p.r = nil, nil
+}
+
+func (p *NATSPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.dropLocked()
+	return nil
+}
@@ -0,0 +1,226 @@
+package outbox_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/k1networth/servicedesk-lite/internal/outbox"
+)
+
+func TestWebhookPublisherReportsPerMessageStatus(t *testing.T) {
+	var topics []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		topics = append(topics, r.Header.Get("X-Outbox-Topic"))
+		if r.Header.Get("X-Outbox-Key") == "bad" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	p := outbox.NewWebhookPublisher(srv.URL, time.Second)
+	errs := p.Publish(context.Background(), []outbox.Message{
+		{Topic: "tickets.events", Key: []byte("ok"), Value: []byte(`{}`)},
+		{Topic: "tickets.events", Key: []byte("bad"), Value: []byte(`{}`)},
+	})
+
+	if errs[0] != nil || errs[1] == nil {
+		t.Fatalf("expected [nil, error], got %v", errs)
+	}
+	if len(topics) != 2 || topics[0] != "tickets.events" {
+		t.Fatalf("unexpected topic headers: %v", topics)
+	}
+}
+
+func TestFilePublisherWritesNDJSON(t *testing.T) {
+	var buf bytes.Buffer
+	p := outbox.NewWriterPublisher(&buf)
+
+	errs := p.Publish(context.Background(), []outbox.Message{
+		{Topic: "tickets.events", Key: []byte("42"), Value: []byte(`{"event_type":"ticket.created"}`)},
+	})
+	if errs[0] != nil {
+		t.Fatalf("publish: %v", errs[0])
+	}
+
+	var rec struct {
+		Topic string          `json:"topic"`
+		Key   string          `json:"key"`
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("decode %q: %v", buf.String(), err)
+	}
+	if rec.Topic != "tickets.events" || rec.Key != "42" || string(rec.Value) != `{"event_type":"ticket.created"}` {
+		t.Fatalf("unexpected record: %+v", rec)
+	}
+}
+
+// fakeNATS accepts connections, records PUB/HPUB subjects and answers PING with PONG.
+// A PUB to a subject listed in reject is answered with "-ERR '<reason>'" and the
+// connection is closed, as nats-server does for protocol violations.
+func fakeNATS(t *testing.T, info string, reject map[string]string) (addr string, subjects <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	ch := make(chan string, 16)
+	serve := func(conn net.Conn) {
+		defer func() { _ = conn.Close() }()
+		_, _ = conn.Write([]byte("INFO " + info + "\r\n"))
+
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			f := strings.Fields(line)
+			switch {
+			case len(f) == 3 && f[0] == "PUB":
+				n, _ := strconv.Atoi(f[2])
+				if _, err := io.CopyN(io.Discard, r, int64(n)+2); err != nil {
+					return
+				}
+				if reason, ok := reject[f[1]]; ok {
+					_, _ = conn.Write([]byte("-ERR '" + reason + "'\r\n"))
+					return
+				}
+				ch <- f[1]
+			case len(f) == 4 && f[0] == "HPUB":
+				hdrLen, _ := strconv.Atoi(f[2])
+				total, _ := strconv.Atoi(f[3])
+				hdr := make([]byte, hdrLen)
+				if _, err := io.ReadFull(r, hdr); err != nil {
+					return
+				}
+				if _, err := io.CopyN(io.Discard, r, int64(total-hdrLen)+2); err != nil {
+					return
+				}
+				// Report "<subject> <header lines>" without the NATS/1.0 preamble.
+				ch <- f[1] + " " + strings.Join(strings.Fields(string(hdr))[1:], " ")
+			case len(f) == 1 && f[0] == "PING":
+				_, _ = conn.Write([]byte("PONG\r\n"))
+			}
+		}
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+	return ln.Addr().String(), ch
+}
+
+func TestNATSPublisherPublishesAndWaitsForPong(t *testing.T) {
+	addr, subjects := fakeNATS(t, `{"headers":true}`, nil)
+
+	p, err := outbox.NewNATSPublisher("nats://"+addr, time.Second)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer func() { _ = p.Close() }()
+
+	errs := p.Publish(context.Background(), []outbox.Message{
+		{Topic: "tickets.events", Value: []byte(`{"a":1}`)},
+		{Topic: "tickets.sla", Value: []byte(`{"b":2}`), Headers: []outbox.Header{{Key: "event-type", Value: "ticket.sla_breached"}}},
+	})
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("msg %d: %v", i, err)
+		}
+	}
+	if got := []string{<-subjects, <-subjects}; got[0] != "tickets.events" || got[1] != "tickets.sla event-type: ticket.sla_breached" {
+		t.Fatalf("unexpected subjects: %v", got)
+	}
+}
+
+func TestNATSPublisherRejectsBadMessagesOnly(t *testing.T) {
+	addr, subjects := fakeNATS(t, `{"max_payload":16}`, nil)
+
+	p, err := outbox.NewNATSPublisher("nats://"+addr, time.Second)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer func() { _ = p.Close() }()
+
+	errs := p.Publish(context.Background(), []outbox.Message{
+		{Topic: "tickets.events", Value: []byte(`{"a":1}`)},
+		{Topic: "tickets.*", Value: []byte(`{"b":2}`)},
+		{Topic: "tickets.events", Value: []byte(`{"too":"large for the server"}`)},
+		{Topic: "tickets.sla", Value: []byte(`{"c":3}`)},
+	})
+	if errs[0] != nil || errs[3] != nil {
+		t.Fatalf("valid messages must go through, got %v", errs)
+	}
+	for _, i := range []int{1, 2} {
+		if errs[i] == nil || outbox.IsUnavailable(errs[i]) {
+			t.Fatalf("msg %d: expected a per-message error, got %v", i, errs[i])
+		}
+	}
+	if got := []string{<-subjects, <-subjects}; got[0] != "tickets.events" || got[1] != "tickets.sla" {
+		t.Fatalf("unexpected subjects: %v", got)
+	}
+}
+
+func TestNATSPublisherAttributesServerErrorToMessage(t *testing.T) {
+	addr, subjects := fakeNATS(t, `{}`, map[string]string{"tickets.secret": "Permissions Violation for Publish to \"tickets.secret\""})
+
+	p, err := outbox.NewNATSPublisher("nats://"+addr, time.Second)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer func() { _ = p.Close() }()
+
+	errs := p.Publish(context.Background(), []outbox.Message{
+		{Topic: "tickets.events", Value: []byte(`{"a":1}`)},
+		{Topic: "tickets.secret", Value: []byte(`{"b":2}`)},
+		{Topic: "tickets.sla", Value: []byte(`{"c":3}`)},
+	})
+	if errs[0] != nil || errs[2] != nil {
+		t.Fatalf("messages around the rejected one must go through, got %v", errs)
+	}
+	if errs[1] == nil || outbox.IsUnavailable(errs[1]) || !strings.Contains(errs[1].Error(), "Permissions Violation") {
+		t.Fatalf("expected the server error for the rejected message, got %v", errs[1])
+	}
+	if got := []string{<-subjects, <-subjects}; got[0] != "tickets.events" || got[1] != "tickets.sla" {
+		t.Fatalf("unexpected subjects: %v", got)
+	}
+}
+
+func TestNATSPublisherFailsBatchAsUnavailableWhenServerIsGone(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+
+	p, err := outbox.NewNATSPublisher("nats://"+addr, time.Second)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	for i, err := range p.Publish(context.Background(), []outbox.Message{{Topic: "a", Value: []byte("1")}, {Topic: "b", Value: []byte("2")}}) {
+		if !outbox.IsUnavailable(err) {
+			t.Fatalf("msg %d: expected unavailable, got %v", i, err)
+		}
+	}
+}
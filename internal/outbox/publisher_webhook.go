@@ -0,0 +1,72 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// WebhookPublisher POSTs every message body to URL, one request per message.
+// The topic and key travel as X-Outbox-Topic / X-Outbox-Key headers, message headers
+// as HTTP headers of the same name; any 2xx is success.
+// Transport errors, 429 and 5xx count as the endpoint being unavailable.
+type WebhookPublisher struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewWebhookPublisher(url string, timeout time.Duration) *WebhookPublisher {
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return &WebhookPublisher{URL: url, Client: &http.Client{Timeout: timeout}}
+}
+
+func (p *WebhookPublisher) Publish(ctx context.Context, msgs []Message) []error {
+	errs := make([]error, len(msgs))
+	for i, m := range msgs {
+		if ctx.Err() != nil {
+			errs[i] = ctx.Err()
+			continue
+		}
+		errs[i] = p.post(ctx, m)
+	}
+	return errs
+}
+
+func (p *WebhookPublisher) post(ctx context.Context, m Message) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(m.Value))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Outbox-Topic", m.Topic)
+	req.Header.Set("X-Outbox-Key", string(m.Key))
+	for _, h := range m.Headers {
+		req.Header.Set(h.Key, h.Value)
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return &UnavailableError{Err: err}
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err := fmt.Errorf("webhook: unexpected status %d", resp.StatusCode)
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			return &UnavailableError{Err: err}
+		}
+		return err
+	}
+	return nil
+}
+
+func (p *WebhookPublisher) Close() error {
+	p.Client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,92 @@
+package outbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+)
+
+// Route maps event types matching Pattern (path.Match glob, e.g. "ticket.sla_*") to Topic.
+type Route struct {
+	Pattern string `json:"event_type"`
+	Topic   string `json:"topic"`
+}
+
+// Router picks a Kafka topic per event type. Routes are checked in order; the
+// first match wins, otherwise DefaultTopic is used.
+type Router struct {
+	DefaultTopic string  `json:"default_topic"`
+	Routes       []Route `json:"routes"`
+}
+
+var topicNameRe = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,249}$`)
+
+// LoadRouter reads routing rules from a JSON file:
+//
+//	{"default_topic": "tickets.events", "routes": [{"event_type": "ticket.sla_*", "topic": "tickets.sla"}]}
+//
+// An empty path yields a router that sends everything to defaultTopic. A missing
+// default_topic in the file falls back to defaultTopic as well.
+func LoadRouter(file, defaultTopic string) (*Router, error) {
+	r := &Router{DefaultTopic: defaultTopic}
+	if file != "" {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("routes file: %w", err)
+		}
+		if err := json.Unmarshal(b, r); err != nil {
+			return nil, fmt.Errorf("routes file %s: %w", file, err)
+		}
+		if r.DefaultTopic == "" {
+			r.DefaultTopic = defaultTopic
+		}
+	}
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Router) Validate() error {
+	if !topicNameRe.MatchString(r.DefaultTopic) {
+		return fmt.Errorf("routes: invalid default topic %q", r.DefaultTopic)
+	}
+	for i, rt := range r.Routes {
+		if rt.Pattern == "" {
+			return fmt.Errorf("routes[%d]: event_type is empty", i)
+		}
+		if _, err := path.Match(rt.Pattern, ""); err != nil {
+			return fmt.Errorf("routes[%d]: bad event_type pattern %q: %w", i, rt.Pattern, err)
+		}
+		if !topicNameRe.MatchString(rt.Topic) {
+			return fmt.Errorf("routes[%d]: invalid topic %q", i, rt.Topic)
+		}
+	}
+	return nil
+}
+
+func (r *Router) Topic(eventType string) string {
+	for _, rt := range r.Routes {
+		if ok, _ := path.Match(rt.Pattern, eventType); ok {
+			return rt.Topic
+		}
+	}
+	return r.DefaultTopic
+}
+
+// Topics returns every topic the router can produce to, sorted, default included.
+func (r *Router) Topics() []string {
+	seen := map[string]bool{r.DefaultTopic: true}
+	out := []string{r.DefaultTopic}
+	for _, rt := range r.Routes {
+		if !seen[rt.Topic] {
+			seen[rt.Topic] = true
+			out = append(out, rt.Topic)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,45 @@
+package outbox_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/k1networth/servicedesk-lite/internal/outbox"
+)
+
+func TestRouterTopic(t *testing.T) {
+	r := &outbox.Router{
+		DefaultTopic: "tickets.events",
+		Routes: []outbox.Route{
+			{Pattern: "ticket.sla_*", Topic: "tickets.sla"},
+			{Pattern: "ticket.worklog_added", Topic: "tickets.billing"},
+		},
+	}
+	if err := r.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	cases := map[string]string{
+		"ticket.sla_breached":  "tickets.sla",
+		"ticket.worklog_added": "tickets.billing",
+		"ticket.created":       "tickets.events",
+	}
+	for eventType, want := range cases {
+		if got := r.Topic(eventType); got != want {
+			t.Fatalf("%s: expected topic %q, got %q", eventType, want, got)
+		}
+	}
+}
+
+func TestLoadRouterRejectsInvalidRoutes(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "routes.json")
+	body := `{"routes":[{"event_type":"ticket.[","topic":"tickets.bad"}]}`
+	if err := os.WriteFile(file, []byte(body), 0o600); err != nil {
+		t.Fatalf("write routes: %v", err)
+	}
+
+	if _, err := outbox.LoadRouter(file, "tickets.events"); err == nil {
+		t.Fatalf("expected bad pattern to be rejected")
+	}
+}
@@ -0,0 +1,261 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// shardLockSpace is the first key of the two-key advisory lock; the second is the shard number.
+const shardLockSpace = "outbox:shard"
+
+// ShardFilter restricts a claim to aggregates with
+// (hashtext(aggregate_id) & 2147483647) % Total in Owned.
+// Total == 0 disables sharding: every aggregate matches.
+type ShardFilter struct {
+	Total int
+	Owned []int64
+}
+
+// Sharder splits the outbox between relay replicas: shard = hash(aggregate_id) mod Shards.
+// Every replica owns a set of shards through session advisory locks held on a dedicated
+// connection, so all events of an aggregate are published by a single replica.
+//
+// On every heartbeat a replica upserts itself into outbox_relay_members, drops members
+// silent for longer than TTL, and resizes its set to ceil(Shards / live members):
+// extra shards are unlocked, free ones are taken with pg_try_advisory_lock. When a
+// replica dies its connection closes, Postgres releases its locks and the survivors pick
+// the shards up on their next heartbeat. If the connection breaks the replica drops all
+// shards immediately and claims nothing until it reconnects.
+type Sharder struct {
+	DatabaseURL string
+	Shards      int
+	Heartbeat   time.Duration
+	TTL         time.Duration
+	MemberID    string
+	Log         *slog.Logger
+	Metrics     *Metrics
+
+	mu    sync.Mutex
+	owned map[int]bool
+
+	// busy is read-locked by a relay tick for its whole duration (Lease). Shards are
+	// given up only under the write lock, so a batch claimed through a shard is finished
+	// (sent, failed or released) before another replica can take the shard over and
+	// publish later events of the same aggregates.
+	busy sync.RWMutex
+}
+
+// DefaultMemberID is hostname-pid, unique enough for replicas in one cluster.
+func DefaultMemberID() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// Filter returns the shards currently owned by this replica.
+func (s *Sharder) Filter() ShardFilter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f := ShardFilter{Total: s.Shards, Owned: make([]int64, 0, len(s.owned))}
+	for n := range s.owned {
+		f.Owned = append(f.Owned, int64(n))
+	}
+	sort.Slice(f.Owned, func(i, j int) bool { return f.Owned[i] < f.Owned[j] })
+	return f
+}
+
+// Lease returns the owned shards and pins them until done is called. The relay holds a
+// lease from claim to bookkeeping, so rebalancing waits for the in-flight batch.
+func (s *Sharder) Lease() (f ShardFilter, done func()) {
+	s.busy.RLock()
+	return s.Filter(), s.busy.RUnlock
+}
+
+// Maintainer reports whether this replica owns shard 0 and should run cluster-wide
+// maintenance (stuck-row reset, lag and blocked-aggregate gauges).
+func (s *Sharder) Maintainer() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.owned[0]
+}
+
+// Run blocks until ctx is cancelled, reconnecting with backoff on errors.
+// rebalanced is signalled (non-blocking) whenever the owned set grows.
+func (s *Sharder) Run(ctx context.Context, rebalanced chan<- struct{}) {
+	failures := 0
+	for {
+		err := s.hold(ctx, rebalanced, func() { failures = 0 })
+		s.setOwned(nil)
+		if ctx.Err() != nil {
+			return
+		}
+
+		failures++
+		delay := listenerBackoff(failures)
+		s.Log.Warn("outbox_sharder_disconnected",
+			slog.String("member_id", s.MemberID),
+			slog.Int("failures", failures),
+			slog.String("retry_in", delay.String()),
+			slog.String("err", err.Error()),
+		)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *Sharder) hold(ctx context.Context, rebalanced chan<- struct{}, onConnected func()) error {
+	conn, err := pgx.Connect(ctx, s.DatabaseURL)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// On shutdown the drained batch finishes before the session (and its locks) ends.
+		// A broken connection has already lost the locks; waiting is harmless then.
+		s.busy.Lock()
+		s.setOwned(nil)
+		s.busy.Unlock()
+
+		// Best effort: the locks go away with the session anyway.
+		cctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		_, _ = conn.Exec(cctx, `DELETE FROM outbox_relay_members WHERE member_id = $1;`, s.MemberID)
+		_ = conn.Close(cctx)
+	}()
+
+	s.Log.Info("outbox_sharder_connected", slog.String("member_id", s.MemberID), slog.Int("shards", s.Shards))
+	onConnected()
+
+	t := time.NewTicker(s.Heartbeat)
+	defer t.Stop()
+
+	owned := map[int]bool{}
+	for {
+		grew, err := s.beat(ctx, conn, owned)
+		if err != nil {
+			return err
+		}
+		s.setOwned(owned)
+		if grew {
+			signal(rebalanced)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// shardConn is the part of *pgx.Conn used by a heartbeat (faked in tests).
+type shardConn interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// beat runs one heartbeat and adjusts owned in place. It reports whether new shards were taken.
+func (s *Sharder) beat(ctx context.Context, conn shardConn, owned map[int]bool) (bool, error) {
+	const upsert = `
+INSERT INTO outbox_relay_members (member_id, heartbeat_at)
+VALUES ($1, now())
+ON CONFLICT (member_id) DO UPDATE SET heartbeat_at = now();
+`
+	if _, err := conn.Exec(ctx, upsert, s.MemberID); err != nil {
+		return false, err
+	}
+	if _, err := conn.Exec(ctx, `DELETE FROM outbox_relay_members WHERE heartbeat_at < now() - $1::interval;`,
+		fmt.Sprintf("%fs", s.TTL.Seconds())); err != nil {
+		return false, err
+	}
+
+	var members int
+	if err := conn.QueryRow(ctx, `SELECT COUNT(*) FROM outbox_relay_members;`).Scan(&members); err != nil {
+		return false, err
+	}
+	fair := (s.Shards + max(members, 1) - 1) / max(members, 1)
+
+	before := len(owned)
+
+	// Release from the top so shard 0 (maintenance) stays put when possible.
+	var release []int
+	for n := s.Shards - 1; n >= 0 && len(owned)-len(release) > fair; n-- {
+		if owned[n] {
+			release = append(release, n)
+		}
+	}
+	if len(release) > 0 {
+		// Wait for the in-flight tick and hide the shards from the next one before
+		// unlocking them; see busy.
+		s.busy.Lock()
+		for _, n := range release {
+			delete(owned, n)
+		}
+		s.setOwned(owned)
+		s.busy.Unlock()
+
+		for _, n := range release {
+			if _, err := conn.Exec(ctx, `SELECT pg_advisory_unlock(hashtext($1), $2);`, shardLockSpace, n); err != nil {
+				return false, err
+			}
+			s.Metrics.ShardRebalancesTotal.WithLabelValues("released").Inc()
+			s.Log.Info("outbox_shard_released", slog.String("member_id", s.MemberID), slog.Int("shard", n), slog.Int("members", members))
+		}
+	}
+
+	grew := false
+	for n := 0; n < s.Shards && len(owned) < fair; n++ {
+		if owned[n] {
+			continue
+		}
+		var ok bool
+		if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock(hashtext($1), $2);`, shardLockSpace, n).Scan(&ok); err != nil {
+			return false, err
+		}
+		if !ok {
+			continue
+		}
+		owned[n] = true
+		grew = true
+		s.Metrics.ShardRebalancesTotal.WithLabelValues("acquired").Inc()
+		s.Log.Info("outbox_shard_acquired", slog.String("member_id", s.MemberID), slog.Int("shard", n), slog.Int("members", members))
+	}
+
+	if len(owned) != before || grew {
+		s.Log.Info("outbox_shards_owned", slog.String("member_id", s.MemberID), slog.Any("shards", keys(owned)))
+	}
+	return grew, nil
+}
+
+func (s *Sharder) setOwned(owned map[int]bool) {
+	cp := make(map[int]bool, len(owned))
+	for n := range owned {
+		cp[n] = true
+	}
+
+	s.mu.Lock()
+	s.owned = cp
+	s.mu.Unlock()
+
+	s.Metrics.ShardsOwned.Set(float64(len(cp)))
+}
+
+func keys(m map[int]bool) []int {
+	out := make([]int, 0, len(m))
+	for n := range m {
+		out = append(out, n)
+	}
+	sort.Ints(out)
+	return out
+}
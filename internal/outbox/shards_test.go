@@ -0,0 +1,169 @@
+package outbox_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/k1networth/servicedesk-lite/internal/outbox"
+)
+
+// fakeShardConn answers a heartbeat: members live replicas, shards in taken locked by
+// other replicas. It records the shards unlocked.
+type fakeShardConn struct {
+	members int
+	taken   map[int]bool
+
+	mu       sync.Mutex
+	unlocked []int
+}
+
+func (c *fakeShardConn) Exec(_ context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	if strings.Contains(sql, "pg_advisory_unlock") {
+		c.mu.Lock()
+		c.unlocked = append(c.unlocked, args[1].(int))
+		c.mu.Unlock()
+	}
+	return pgconn.NewCommandTag(""), nil
+}
+
+func (c *fakeShardConn) QueryRow(_ context.Context, sql string, args ...any) pgx.Row {
+	if strings.Contains(sql, "pg_try_advisory_lock") {
+		return shardRow{!c.taken[args[1].(int)]}
+	}
+	return shardRow{c.members}
+}
+
+func (c *fakeShardConn) released() []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]int(nil), c.unlocked...)
+}
+
+type shardRow []any
+
+func (r shardRow) Scan(dest ...any) error {
+	for i, d := range dest {
+		switch d := d.(type) {
+		case *int:
+			*d = r[i].(int)
+		case *bool:
+			*d = r[i].(bool)
+		default:
+			return fmt.Errorf("unexpected scan target %T", d)
+		}
+	}
+	return nil
+}
+
+func newSharder(shards int) *outbox.Sharder {
+	return &outbox.Sharder{
+		Shards:   shards,
+		MemberID: "test",
+		Log:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Metrics:  outbox.NewMetrics(prometheus.NewRegistry(), &outbox.Router{DefaultTopic: "tickets.events"}),
+	}
+}
+
+func TestSharderTakesFairShare(t *testing.T) {
+	cases := []struct {
+		members int
+		taken   map[int]bool
+		want    string
+	}{
+		{members: 1, want: "[0 1 2 3 4 5 6 7]"},
+		{members: 3, want: "[0 1 2]"},                               // ceil(8/3)
+		{members: 3, taken: map[int]bool{1: true}, want: "[0 2 3]"}, // skips shards of others
+		{members: 10, taken: map[int]bool{0: true}, want: "[1]"},    // at least one
+		{members: 4, taken: map[int]bool{0: true, 1: true}, want: "[2 3]"},
+	}
+	for _, c := range cases {
+		s := newSharder(8)
+		owned := map[int]bool{}
+		grew, err := s.Beat(context.Background(), &fakeShardConn{members: c.members, taken: c.taken}, owned)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fmt.Sprint(ownedShards(owned)); got != c.want || !grew {
+			t.Errorf("%d members, taken %v: got %s (grew %v), want %s", c.members, c.taken, got, grew, c.want)
+		}
+	}
+}
+
+func TestSharderReleasesBeyondFairShare(t *testing.T) {
+	s := newSharder(8)
+	owned := map[int]bool{}
+	if _, err := s.Beat(context.Background(), &fakeShardConn{members: 1}, owned); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two more replicas joined: keep ceil(8/3) = 3, giving up the top shards first.
+	conn := &fakeShardConn{members: 3}
+	grew, err := s.Beat(context.Background(), conn, owned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(conn.released()); got != "[7 6 5 4 3]" || grew {
+		t.Fatalf("released %s (grew %v), want [7 6 5 4 3]", got, grew)
+	}
+	if got := fmt.Sprint(s.Filter().Owned); got != "[0 1 2]" || !s.Maintainer() {
+		t.Fatalf("expected to keep shards [0 1 2] including the maintainer shard, got %s", got)
+	}
+}
+
+func TestSharderWaitsForLeaseBeforeReleasing(t *testing.T) {
+	s := newSharder(4)
+	owned := map[int]bool{}
+	if _, err := s.Beat(context.Background(), &fakeShardConn{members: 1}, owned); err != nil {
+		t.Fatal(err)
+	}
+
+	_, done := s.Lease() // a tick with a claimed batch in flight
+	conn := &fakeShardConn{members: 2}
+	finished := make(chan error, 1)
+	go func() {
+		_, err := s.Beat(context.Background(), conn, owned)
+		finished <- err
+	}()
+
+	select {
+	case <-finished:
+		t.Fatal("shards were released while a tick held them")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if got := conn.released(); len(got) != 0 {
+		t.Fatalf("unlocked %v under a lease", got)
+	}
+
+	done()
+	if err := <-finished; err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(conn.released()); got != "[3 2]" {
+		t.Fatalf("released %s after the lease, want [3 2]", got)
+	}
+	f, done := s.Lease()
+	done()
+	if fmt.Sprint(f.Owned) != "[0 1]" {
+		t.Fatalf("the next tick must not see released shards, got %v", f.Owned)
+	}
+}
+
+func ownedShards(owned map[int]bool) []int {
+	var out []int
+	for n := range 8 {
+		if owned[n] {
+			out = append(out, n)
+		}
+	}
+	return out
+}
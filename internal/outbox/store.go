@@ -6,21 +6,36 @@ import (
 	"encoding/json"
 	"fmt"
 	"time"
+
+	"github.com/k1networth/servicedesk-lite/internal/shared/events"
 )
 
 type Event struct {
-	ID          int64
-	EventID     string
-	Aggregate   string
-	AggregateID string
-	EventType   string
-	Payload     json.RawMessage
-	CreatedAt   time.Time
-	Attempts    int
+	ID            int64
+	EventID       string
+	Aggregate     string
+	AggregateID   string
+	EventType     string
+	SchemaVersion int // payload version, see events.ValidatePayload
+	Payload       json.RawMessage
+	CreatedAt     time.Time
+	Attempts      int
+	Priority      int
+
+	// Metadata captured by Writer from the producer's context; empty for older rows.
+	RequestID   string
+	TenantID    string
+	Traceparent string
+	// PayloadRef is set when the payload was offloaded by Writer (Payload is then null).
+	PayloadRef *events.PayloadRef
 }
 
 type Store struct {
 	db *sql.DB
+
+	// MaxPriorityWait is the starvation guard of the priority lanes: an event due for
+	// longer than this is claimed ahead of all lanes. Zero disables aging.
+	MaxPriorityWait time.Duration
 }
 
 func NewStore(db *sql.DB) *Store { return &Store{db: db} }
@@ -46,18 +61,62 @@ WHERE status = 'processing'
 	return res.RowsAffected()
 }
 
-func (s *Store) ClaimPending(ctx context.Context, batchSize int) ([]Event, error) {
-	if batchSize <= 0 {
-		batchSize = 50
-	}
+// ClaimPending moves up to batchSize ready events of the given shards to processing,
+// highest priority first, then oldest first. Events due for longer than MaxPriorityWait
+// are claimed ahead of all lanes so bulk lanes cannot starve.
+func (s *Store) ClaimPending(ctx context.Context, batchSize int, shards ShardFilter) ([]Event, error) {
+	return s.claim(ctx, false, batchSize, shards)
+}
 
-	const q = `
+// ClaimPendingOrdered is ClaimPending with strict per-aggregate ordering: an event is
+// claimable only if no earlier event of the same aggregate is still pending (including
+// waiting for a retry), processing or failed. So at most one event per aggregate is
+// in flight, and a dead event blocks its aggregate until it is requeued or skipped.
+// Scheduled events that are not due yet do not block: they are ordered by delivery time.
+func (s *Store) ClaimPendingOrdered(ctx context.Context, batchSize int, shards ShardFilter) ([]Event, error) {
+	return s.claim(ctx, true, batchSize, shards)
+}
+
+// claimHeadOnly restricts a claim to the head event of each aggregate (ClaimPendingOrdered).
+const claimHeadOnly = `
+    AND NOT EXISTS (
+      SELECT 1
+      FROM outbox p
+      WHERE p.aggregate = o.aggregate
+        AND p.aggregate_id = o.aggregate_id
+        AND p.id < o.id
+        AND p.status IN ('pending', 'processing', 'failed')
+        AND NOT (p.status = 'pending' AND p.deliver_at > now())
+    )`
+
+// Lane predicates: $4 is the aging threshold or the priority. Each lane is its own
+// query that range-scans only due rows (next_retry_at <= now()), so scheduled events
+// that are not due yet are never read: a priority through outbox_pending_priority_idx
+// (priority, next_retry_at, created_at), aged events through outbox_pending_ready_idx.
+// An event is aged once it has been due (next_retry_at, i.e. deliver_at or created_at
+// until its first retry) for longer than MaxPriorityWait.
+const (
+	claimAged     = `AND o.next_retry_at < now() - $4::interval`
+	claimPriority = `AND o.priority = $4::smallint`
+)
+
+// claimQuery moves up to $1 due events of the lane to processing. The UPDATE matches on
+// (id, created_at) so that on the partitioned table each row is found through its
+// partition's primary key.
+func claimQuery(lane string, ordered bool) string {
+	head := ""
+	if ordered {
+		head = claimHeadOnly
+	}
+	return `
 WITH cte AS (
-  SELECT id
-  FROM outbox
-  WHERE status = 'pending'
-    AND next_retry_at <= now()
-  ORDER BY created_at
+  SELECT o.id, o.created_at
+  FROM outbox o
+  WHERE o.status = 'pending'
+    AND o.next_retry_at <= now()
+    ` + lane + `
+    AND ($2::int = 0 OR (hashtext(o.aggregate_id) & 2147483647) % GREATEST($2::int, 1) = ANY($3::bigint[]))` + head + `
+  ORDER BY o.created_at
   LIMIT $1
   FOR UPDATE SKIP LOCKED
 )
@@ -68,10 +127,56 @@ SET status = 'processing',
     updated_at = now()
 FROM cte
 WHERE o.id = cte.id
-RETURNING o.id, o.event_id, o.aggregate, o.aggregate_id, o.event_type, o.payload, o.created_at, o.attempts;
+  AND o.created_at = cte.created_at
+RETURNING o.id, o.event_id, o.aggregate, o.aggregate_id, o.event_type, o.schema_version, o.payload, o.created_at, o.attempts,
+          o.priority, COALESCE(o.request_id, ''), COALESCE(o.tenant_id, ''), COALESCE(o.traceparent, ''), o.payload_ref;
 `
+}
 
-	rows, err := s.db.QueryContext(ctx, q, batchSize)
+// claim fills the batch lane by lane: aged events (if MaxPriorityWait is set), then
+// high, normal and low, each taking what the previous ones left. Every lane commits on
+// its own; if a lane fails after earlier ones claimed events, those are returned (they
+// are already processing) and the error shows up again on the next call.
+func (s *Store) claim(ctx context.Context, ordered bool, batchSize int, shards ShardFilter) ([]Event, error) {
+	if batchSize <= 0 {
+		batchSize = 50
+	}
+	if shards.Total > 0 && len(shards.Owned) == 0 {
+		return nil, nil
+	}
+	owned := shards.Owned
+	if owned == nil {
+		owned = []int64{}
+	}
+
+	var out []Event
+	step := func(lane string, arg any) error {
+		evs, err := s.claimLane(ctx, claimQuery(lane, ordered), batchSize-len(out), shards.Total, owned, arg)
+		out = append(out, evs...)
+		return err
+	}
+	if s.MaxPriorityWait > 0 {
+		if err := step(claimAged, fmt.Sprintf("%fs", s.MaxPriorityWait.Seconds())); err != nil {
+			return claimed(out, err)
+		}
+	}
+	for i := len(Priorities) - 1; i >= 0 && len(out) < batchSize; i-- {
+		if err := step(claimPriority, Priorities[i]); err != nil {
+			return claimed(out, err)
+		}
+	}
+	return out, nil
+}
+
+func claimed(out []Event, err error) ([]Event, error) {
+	if len(out) > 0 {
+		return out, nil
+	}
+	return nil, err
+}
+
+func (s *Store) claimLane(ctx context.Context, q string, limit int, total int, owned []int64, lane any) ([]Event, error) {
+	rows, err := s.db.QueryContext(ctx, q, limit, total, owned, lane)
 	if err != nil {
 		return nil, err
 	}
@@ -80,9 +185,17 @@ RETURNING o.id, o.event_id, o.aggregate, o.aggregate_id, o.event_type, o.payload
 	var out []Event
 	for rows.Next() {
 		var e Event
-		if err := rows.Scan(&e.ID, &e.EventID, &e.Aggregate, &e.AggregateID, &e.EventType, &e.Payload, &e.CreatedAt, &e.Attempts); err != nil {
+		var ref []byte
+		if err := rows.Scan(&e.ID, &e.EventID, &e.Aggregate, &e.AggregateID, &e.EventType, &e.SchemaVersion, &e.Payload, &e.CreatedAt, &e.Attempts,
+			&e.Priority, &e.RequestID, &e.TenantID, &e.Traceparent, &ref); err != nil {
 			return nil, err
 		}
+		// The rows are already claimed, so a malformed reference must not fail the batch:
+		// it is left nil and the null payload is rejected by the relay's schema check.
+		var pr events.PayloadRef
+		if ref != nil && json.Unmarshal(ref, &pr) == nil {
+			e.PayloadRef = &pr
+		}
 		out = append(out, e)
 	}
 	if err := rows.Err(); err != nil {
@@ -91,7 +204,29 @@ RETURNING o.id, o.event_id, o.aggregate, o.aggregate_id, o.event_type, o.payload
 	return out, nil
 }
 
-func (s *Store) MarkSent(ctx context.Context, id int64) error {
+// The updates below match rows on (id, created_at), the primary key of every partition,
+// so each one touches only the partition holding the row instead of probing all of them.
+
+func (s *Store) MarkSent(ctx context.Context, e Event) error {
+	const q = `
+UPDATE outbox
+SET status = 'sent',
+    sent_at = now(),
+    processing_started_at = NULL,
+    last_error = NULL,
+    updated_at = now()
+WHERE id = $1
+  AND created_at = $2;
+`
+	_, err := s.db.ExecContext(ctx, q, e.ID, e.CreatedAt)
+	return err
+}
+
+// MarkSentBatch marks all events as sent with a single UPDATE.
+func (s *Store) MarkSentBatch(ctx context.Context, evs []Event) error {
+	if len(evs) == 0 {
+		return nil
+	}
 	const q = `
 UPDATE outbox
 SET status = 'sent',
@@ -99,57 +234,130 @@ SET status = 'sent',
     processing_started_at = NULL,
     last_error = NULL,
     updated_at = now()
-WHERE id = $1;
+WHERE created_at = ANY($2::timestamptz[])
+  AND (id, created_at) IN (SELECT * FROM unnest($1::bigint[], $2::timestamptz[]));
 `
-	_, err := s.db.ExecContext(ctx, q, id)
+	ids, created := eventKeys(evs)
+	_, err := s.db.ExecContext(ctx, q, ids, created)
 	return err
 }
 
-func (s *Store) MarkFailed(ctx context.Context, id int64, nextRetryAt time.Time, errMsg string) error {
+// Release returns claimed events to pending without charging the attempt taken by the
+// claim, e.g. when the sink was down and the events never had a real chance.
+func (s *Store) Release(ctx context.Context, evs []Event, errMsg string) error {
+	if len(evs) == 0 {
+		return nil
+	}
 	const q = `
 UPDATE outbox
 SET status = 'pending',
     processing_started_at = NULL,
-    next_retry_at = $2,
+    attempts = GREATEST(attempts - 1, 0),
+    next_retry_at = now(),
     last_error = $3,
     updated_at = now()
-WHERE id = $1;
+WHERE created_at = ANY($2::timestamptz[])
+  AND (id, created_at) IN (SELECT * FROM unnest($1::bigint[], $2::timestamptz[]))
+  AND status = 'processing';
+`
+	ids, created := eventKeys(evs)
+	_, err := s.db.ExecContext(ctx, q, ids, created, errMsg)
+	return err
+}
+
+func (s *Store) MarkFailed(ctx context.Context, e Event, nextRetryAt time.Time, errMsg string) error {
+	const q = `
+UPDATE outbox
+SET status = 'pending',
+    processing_started_at = NULL,
+    next_retry_at = $3,
+    last_error = $4,
+    updated_at = now()
+WHERE id = $1
+  AND created_at = $2;
 `
-	_, err := s.db.ExecContext(ctx, q, id, nextRetryAt, errMsg)
+	_, err := s.db.ExecContext(ctx, q, e.ID, e.CreatedAt, nextRetryAt, errMsg)
 	return err
 }
 
-func (s *Store) MarkDead(ctx context.Context, id int64, errMsg string) error {
+func (s *Store) MarkDead(ctx context.Context, e Event, errMsg string) error {
 	const q = `
 UPDATE outbox
 SET status = 'failed',
     failed_at = COALESCE(failed_at, now()),
     processing_started_at = NULL,
-    last_error = $2,
+    last_error = $3,
     updated_at = now()
-WHERE id = $1;
+WHERE id = $1
+  AND created_at = $2;
 `
-	_, err := s.db.ExecContext(ctx, q, id, errMsg)
+	_, err := s.db.ExecContext(ctx, q, e.ID, e.CreatedAt, errMsg)
 	return err
 }
 
-func (s *Store) LagSeconds(ctx context.Context) (float64, error) {
+func eventKeys(evs []Event) ([]int64, []time.Time) {
+	ids := make([]int64, len(evs))
+	created := make([]time.Time, len(evs))
+	for i, e := range evs {
+		ids[i], created[i] = e.ID, e.CreatedAt
+	}
+	return ids, created
+}
+
+// LagSecondsByPriority is the age of the oldest pending event of each priority that has
+// any; scheduled events count from their deliver_at, and those not due yet are ignored.
+func (s *Store) LagSecondsByPriority(ctx context.Context) (map[int]float64, error) {
 	const q = `
-SELECT EXTRACT(EPOCH FROM (now() - created_at))
+SELECT priority, EXTRACT(EPOCH FROM (now() - MIN(COALESCE(deliver_at, created_at))))
 FROM outbox
 WHERE status = 'pending'
-ORDER BY created_at
-LIMIT 1;
+  AND (deliver_at IS NULL OR deliver_at <= now())
+GROUP BY priority;
 `
-	var v sql.NullFloat64
-	if err := s.db.QueryRowContext(ctx, q).Scan(&v); err != nil {
-		if err == sql.ErrNoRows {
-			return 0, nil
+	rows, err := s.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	out := map[int]float64{}
+	for rows.Next() {
+		var p int
+		var lag float64
+		if err := rows.Scan(&p, &lag); err != nil {
+			return nil, err
 		}
-		return 0, err
+		out[p] = lag
 	}
-	if !v.Valid {
-		return 0, nil
+	return out, rows.Err()
+}
+
+// BlockedAggregates counts aggregates whose oldest unsent event has failed at least
+// once (dead, or pending a retry) while later events wait behind it.
+func (s *Store) BlockedAggregates(ctx context.Context) (int64, error) {
+	const q = `
+WITH heads AS (
+  SELECT DISTINCT ON (aggregate, aggregate_id) aggregate, aggregate_id, id, status, attempts
+  FROM outbox
+  WHERE status IN ('pending', 'processing', 'failed')
+    AND NOT (status = 'pending' AND deliver_at > now())
+  ORDER BY aggregate, aggregate_id, id
+)
+SELECT COUNT(*)
+FROM heads h
+WHERE (h.status = 'failed' OR (h.status = 'pending' AND h.attempts > 0))
+  AND EXISTS (
+    SELECT 1
+    FROM outbox o
+    WHERE o.aggregate = h.aggregate
+      AND o.aggregate_id = h.aggregate_id
+      AND o.id > h.id
+      AND o.status = 'pending'
+  );
+`
+	var n int64
+	if err := s.db.QueryRowContext(ctx, q).Scan(&n); err != nil {
+		return 0, err
 	}
-	return v.Float64, nil
+	return n, nil
 }
@@ -0,0 +1,140 @@
+package outbox_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/k1networth/servicedesk-lite/internal/outbox"
+)
+
+func TestStoreUpdatesMatchOnPartitionKey(t *testing.T) {
+	f, db := newFakeDB(nil)
+	defer func() { _ = db.Close() }()
+	store := outbox.NewStore(db)
+	ctx := context.Background()
+
+	created := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	a := outbox.Event{ID: 1, CreatedAt: created}
+	b := outbox.Event{ID: 2, CreatedAt: created.Add(time.Second)}
+
+	if err := store.MarkSentBatch(ctx, []outbox.Event{a, b}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Release(ctx, []outbox.Event{a}, "sink down"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.MarkFailed(ctx, a, created.Add(time.Minute), "boom"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.MarkDead(ctx, b, "boom"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		fmt.Sprint([]int64{1, 2}, []time.Time{a.CreatedAt, b.CreatedAt}),
+		fmt.Sprint([]int64{1}, []time.Time{a.CreatedAt}),
+		fmt.Sprint(int64(1), a.CreatedAt),
+		fmt.Sprint(int64(2), b.CreatedAt),
+	}
+	stmts := f.queries("UPDATE outbox")
+	if len(stmts) != len(want) {
+		t.Fatalf("expected %d updates, got %d", len(want), len(stmts))
+	}
+	for i, s := range stmts {
+		if got := fmt.Sprint(s.args[0], s.args[1]); got != want[i] {
+			t.Errorf("update %d keyed by %s, want %s", i, got, want[i])
+		}
+	}
+}
+
+// claimLanes answers each lane's claim query with the given event ids, at most $1 of them.
+func claimLanes(aged []int64, byPriority map[int][]int64) func(string, []any) fakeResult {
+	return func(q string, args []any) fakeResult {
+		ids := aged
+		prio := outbox.PriorityHigh
+		if strings.Contains(q, "o.priority = $4") {
+			prio = args[3].(int)
+			ids = byPriority[prio]
+		}
+		ids = ids[:min(len(ids), args[0].(int))]
+		res := fakeResult{cols: make([]string, 14)}
+		for _, id := range ids {
+			res.rows = append(res.rows, []driver.Value{id, fmt.Sprint("e-", id), "ticket", "t-1", "ticket.created", int64(1), []byte("{}"),
+				time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), int64(1), int64(prio), "", "", "", nil})
+		}
+		return res
+	}
+}
+
+func claimedIDs(evs []outbox.Event) []int64 {
+	ids := make([]int64, len(evs))
+	for i, e := range evs {
+		ids[i] = e.ID
+	}
+	return ids
+}
+
+func TestClaimPendingFillsBatchByPriorityAfterAgedEvents(t *testing.T) {
+	f, db := newFakeDB(claimLanes([]int64{9}, map[int][]int64{
+		outbox.PriorityHigh:   {5},
+		outbox.PriorityNormal: {3, 4, 6},
+		outbox.PriorityLow:    {1, 2},
+	}))
+	defer func() { _ = db.Close() }()
+	store := outbox.NewStore(db)
+	store.MaxPriorityWait = 5 * time.Minute
+
+	evs, err := store.ClaimPending(context.Background(), 4, outbox.ShardFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The aged low event (9) goes first, then high, then normal up to the batch size;
+	// older low events (1, 2) wait.
+	if got := fmt.Sprint(claimedIDs(evs)); got != "[9 5 3 4]" {
+		t.Fatalf("claim order: got %s", got)
+	}
+
+	stmts := f.queries("FOR UPDATE SKIP LOCKED")
+	var lanes []string
+	for _, s := range stmts {
+		lanes = append(lanes, fmt.Sprint(s.args[3], "/", s.args[0]))
+	}
+	if got := strings.Join(lanes, " "); got != "300.000000s/4 2/3 1/2" {
+		t.Fatalf("lane/limit sequence: got %s", got)
+	}
+	if !strings.Contains(stmts[0].query, "o.next_retry_at < now() - $4::interval") {
+		t.Fatalf("aging must be bounded by next_retry_at:\n%s", stmts[0].query)
+	}
+	for _, s := range stmts {
+		// Only due rows are scanned, so future scheduled events are never read.
+		if strings.Contains(s.query, "CASE") || !strings.Contains(s.query, "o.next_retry_at <= now()") {
+			t.Fatalf("each lane must range-scan its due rows:\n%s", s.query)
+		}
+	}
+}
+
+func TestClaimPendingOrderedWithoutAging(t *testing.T) {
+	f, db := newFakeDB(claimLanes([]int64{9}, map[int][]int64{outbox.PriorityLow: {1}}))
+	defer func() { _ = db.Close() }()
+
+	evs, err := outbox.NewStore(db).ClaimPendingOrdered(context.Background(), 10, outbox.ShardFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(claimedIDs(evs)); got != "[1]" {
+		t.Fatalf("claim order: got %s", got)
+	}
+	stmts := f.queries("FOR UPDATE SKIP LOCKED")
+	if len(stmts) != 3 || len(f.queries("interval")) != 0 {
+		t.Fatalf("expected the three priority lanes and no aging query, got %d statements", len(stmts))
+	}
+	for _, s := range stmts {
+		if !strings.Contains(s.query, "NOT EXISTS") {
+			t.Fatal("ordered claim must keep the aggregate-head predicate")
+		}
+	}
+}
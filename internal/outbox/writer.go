@@ -0,0 +1,141 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/k1networth/servicedesk-lite/internal/shared/blob"
+	"github.com/k1networth/servicedesk-lite/internal/shared/events"
+	"github.com/k1networth/servicedesk-lite/internal/shared/requestid"
+	"github.com/k1networth/servicedesk-lite/internal/shared/tenant"
+	"github.com/k1networth/servicedesk-lite/internal/shared/tracecontext"
+)
+
+// Execer is satisfied by *sql.Tx (and *sql.DB, *sql.Conn).
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Writer enqueues events into the outbox as part of the caller's transaction, so the event
+// is committed if and only if the business change is.
+type Writer struct {
+	// Blobs and ClaimCheckThreshold enable the claim check: a payload larger than
+	// ClaimCheckThreshold bytes is put into Blobs and the row keeps only a reference.
+	// Zero threshold or nil Blobs keeps every payload inline.
+	Blobs               blob.Store
+	ClaimCheckThreshold int
+}
+
+func NewWriter() *Writer { return &Writer{} }
+
+// Option adjusts a single Enqueue call.
+type Option func(*enqueueOptions)
+
+type enqueueOptions struct {
+	deliverAt time.Time
+	priority  int
+}
+
+// DeliverAt schedules the event: the relay does not publish it before t. A zero t means now.
+func DeliverAt(t time.Time) Option {
+	return func(o *enqueueOptions) { o.deliverAt = t }
+}
+
+// WithPriority sets the priority lane (PriorityLow for bulk work); the default is PriorityNormal.
+func WithPriority(p int) Option {
+	return func(o *enqueueOptions) { o.priority = p }
+}
+
+// Enqueue inserts an event for (aggregate, aggregateID). payload is a typed payload
+// (events.TicketCreated, ...) or pre-encoded JSON ([]byte, json.RawMessage). The schema
+// version is the current one for eventType; request_id, tenant and traceparent are taken
+// from ctx. Payloads of types with a registered schema are validated here, so a breaking
+// change fails the producing request instead of reaching the relay.
+func (w *Writer) Enqueue(ctx context.Context, tx Execer, aggregate, aggregateID, eventType string, payload any, opts ...Option) error {
+	o := enqueueOptions{priority: PriorityNormal}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.priority < PriorityLow || o.priority > PriorityHigh {
+		return fmt.Errorf("outbox: invalid priority %d", o.priority)
+	}
+
+	var b []byte
+	switch p := payload.(type) {
+	case json.RawMessage:
+		b = p
+	case []byte:
+		b = p
+	default:
+		var err error
+		if b, err = json.Marshal(payload); err != nil {
+			return fmt.Errorf("outbox: marshal %s payload: %w", eventType, err)
+		}
+	}
+
+	version := events.CurrentSchemaVersion(eventType)
+	if err := events.ValidatePayload(eventType, version, b); err != nil && !errors.Is(err, events.ErrNoSchema) {
+		return fmt.Errorf("outbox: %w", err)
+	}
+	if version == 0 {
+		version = 1
+	}
+
+	// The blob is written before the row; if the transaction rolls back it stays orphaned,
+	// which is harmless since blobs are content-addressed.
+	var ref []byte
+	if w.Blobs != nil && w.ClaimCheckThreshold > 0 && len(b) > w.ClaimCheckThreshold {
+		pr, err := blob.Offload(ctx, w.Blobs, b)
+		if err != nil {
+			return fmt.Errorf("outbox: offload %s payload: %w", eventType, err)
+		}
+		if ref, err = json.Marshal(pr); err != nil {
+			return err
+		}
+		b = []byte("null")
+	}
+
+	var deliverAt sql.NullTime
+	if !o.deliverAt.IsZero() {
+		deliverAt = sql.NullTime{Time: o.deliverAt, Valid: true}
+	}
+
+	const q = `
+INSERT INTO outbox (aggregate, aggregate_id, event_type, schema_version, payload, request_id, tenant_id, traceparent,
+                    deliver_at, next_retry_at, priority, payload_ref)
+VALUES ($1, $2, $3, $4, $5::jsonb, NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, ''),
+        $9::timestamptz, COALESCE($9::timestamptz, now()), $10, $11::jsonb);
+`
+	_, err := tx.ExecContext(ctx, q,
+		aggregate, aggregateID, eventType, version, b,
+		requestid.Get(ctx), tenant.Get(ctx), tracecontext.Get(ctx),
+		deliverAt, o.priority, ref,
+	)
+	return err
+}
+
+// Cancel cancels the scheduled (DeliverAt) events of eventType for one aggregate that the
+// relay has not published yet, e.g. a reminder once the ticket is resolved. Events already
+// claimed by the relay are not affected. It returns the number of cancelled events.
+func (w *Writer) Cancel(ctx context.Context, tx Execer, aggregate, aggregateID, eventType string) (int64, error) {
+	const q = `
+UPDATE outbox
+SET status = 'cancelled',
+    last_error = 'cancelled',
+    updated_at = now()
+WHERE aggregate = $1
+  AND aggregate_id = $2
+  AND event_type = $3
+  AND status = 'pending'
+  AND deliver_at IS NOT NULL;
+`
+	res, err := tx.ExecContext(ctx, q, aggregate, aggregateID, eventType)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
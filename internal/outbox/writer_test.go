@@ -0,0 +1,183 @@
+package outbox_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/k1networth/servicedesk-lite/internal/outbox"
+	"github.com/k1networth/servicedesk-lite/internal/shared/blob"
+	"github.com/k1networth/servicedesk-lite/internal/shared/events"
+	"github.com/k1networth/servicedesk-lite/internal/shared/requestid"
+	"github.com/k1networth/servicedesk-lite/internal/shared/tenant"
+	"github.com/k1networth/servicedesk-lite/internal/shared/tracecontext"
+)
+
+// recordingTx captures the last statement instead of running it.
+type recordingTx struct {
+	query string
+	args  []any
+}
+
+func (tx *recordingTx) ExecContext(_ context.Context, query string, args ...any) (sql.Result, error) {
+	tx.query, tx.args = query, args
+	return driver.RowsAffected(1), nil
+}
+
+func TestWriterEnqueueFillsMetadataFromContext(t *testing.T) {
+	tp := events.NewTraceparent()
+	ctx := requestid.With(context.Background(), "req-1")
+	ctx = tenant.With(ctx, "acme")
+	ctx = tracecontext.With(ctx, tp)
+
+	tx := &recordingTx{}
+	err := outbox.NewWriter().Enqueue(ctx, tx, "ticket", "t-1", events.EventTypeTicketCreated, events.TicketCreated{
+		TicketID: "t-1", Title: "VPN is down", Status: "new", RequesterOrg: "acme", CreatedAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if !strings.Contains(tx.query, "INSERT INTO outbox") {
+		t.Fatalf("unexpected query: %s", tx.query)
+	}
+
+	want := []any{"ticket", "t-1", events.EventTypeTicketCreated, events.CurrentSchemaVersion(events.EventTypeTicketCreated)}
+	for i, w := range want {
+		if tx.args[i] != w {
+			t.Fatalf("arg %d: got %v, want %v", i, tx.args[i], w)
+		}
+	}
+	if tx.args[5] != "req-1" || tx.args[6] != "acme" || tx.args[7] != tp {
+		t.Fatalf("metadata not taken from context: %v", tx.args[5:])
+	}
+}
+
+func TestWriterEnqueueRejectsInvalidPayload(t *testing.T) {
+	tx := &recordingTx{}
+	err := outbox.NewWriter().Enqueue(context.Background(), tx, "ticket", "t-1", events.EventTypeTicketCreated,
+		[]byte(`{"ticket_id":"t-1"}`))
+
+	var se *events.SchemaError
+	if !errors.As(err, &se) {
+		t.Fatalf("expected SchemaError, got %v", err)
+	}
+	if tx.query != "" {
+		t.Fatalf("nothing should be inserted")
+	}
+}
+
+func TestWriterEnqueueAcceptsTypesWithoutSchema(t *testing.T) {
+	tx := &recordingTx{}
+	if err := outbox.NewWriter().Enqueue(context.Background(), tx, "invoice", "42", "billing.invoice_paid",
+		map[string]any{"amount": 10}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if tx.args[3] != 1 || string(tx.args[4].([]byte)) != `{"amount":10}` {
+		t.Fatalf("unexpected args: %v", tx.args)
+	}
+}
+
+func TestWriterEnqueueSchedulesDelivery(t *testing.T) {
+	w := outbox.NewWriter()
+	payload := map[string]any{"ticket_id": "t-1"}
+
+	tx := &recordingTx{}
+	if err := w.Enqueue(context.Background(), tx, "ticket", "t-1", "ticket.reminder", payload); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if at := tx.args[8].(sql.NullTime); at.Valid {
+		t.Fatalf("expected immediate delivery, got %v", at)
+	}
+
+	due := time.Now().Add(72 * time.Hour)
+	if err := w.Enqueue(context.Background(), tx, "ticket", "t-1", "ticket.reminder", payload, outbox.DeliverAt(due)); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if at := tx.args[8].(sql.NullTime); !at.Valid || !at.Time.Equal(due) {
+		t.Fatalf("expected deliver_at %v, got %v", due, at)
+	}
+	if !strings.Contains(tx.query, "next_retry_at") {
+		t.Fatalf("deliver_at must seed next_retry_at: %s", tx.query)
+	}
+}
+
+func TestWriterCancelTargetsPendingScheduledEvents(t *testing.T) {
+	tx := &recordingTx{}
+	n, err := outbox.NewWriter().Cancel(context.Background(), tx, "ticket", "t-1", "ticket.reminder")
+	if err != nil || n != 1 {
+		t.Fatalf("cancel: n=%d err=%v", n, err)
+	}
+	for _, want := range []string{"status = 'cancelled'", "status = 'pending'", "deliver_at IS NOT NULL"} {
+		if !strings.Contains(tx.query, want) {
+			t.Fatalf("query misses %q: %s", want, tx.query)
+		}
+	}
+	if tx.args[0] != "ticket" || tx.args[1] != "t-1" || tx.args[2] != "ticket.reminder" {
+		t.Fatalf("unexpected args: %v", tx.args)
+	}
+}
+
+func TestWriterEnqueuePriority(t *testing.T) {
+	w := outbox.NewWriter()
+	payload := map[string]any{"ticket_id": "t-1"}
+
+	tx := &recordingTx{}
+	if err := w.Enqueue(context.Background(), tx, "ticket", "t-1", "ticket.reemitted", payload); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if tx.args[9] != outbox.PriorityNormal {
+		t.Fatalf("expected normal priority by default, got %v", tx.args[9])
+	}
+
+	if err := w.Enqueue(context.Background(), tx, "ticket", "t-1", "ticket.reemitted", payload, outbox.WithPriority(outbox.PriorityLow)); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if tx.args[9] != outbox.PriorityLow {
+		t.Fatalf("expected low priority, got %v", tx.args[9])
+	}
+
+	if err := w.Enqueue(context.Background(), &recordingTx{}, "ticket", "t-1", "ticket.reemitted", payload, outbox.WithPriority(7)); err == nil {
+		t.Fatalf("expected out-of-range priority to be rejected")
+	}
+}
+
+func TestWriterEnqueueOffloadsLargePayload(t *testing.T) {
+	blobs, err := blob.NewFSStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("blob store: %v", err)
+	}
+	w := &outbox.Writer{Blobs: blobs, ClaimCheckThreshold: 64}
+
+	tx := &recordingTx{}
+	small := map[string]any{"ticket_id": "t-1"}
+	if err := w.Enqueue(context.Background(), tx, "ticket", "t-1", "ticket.imported", small); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if ref := tx.args[10].([]byte); ref != nil {
+		t.Fatalf("small payload must stay inline, got ref %s", ref)
+	}
+
+	large := map[string]any{"ticket_id": "t-1", "body": strings.Repeat("x", 1024)}
+	if err := w.Enqueue(context.Background(), tx, "ticket", "t-1", "ticket.imported", large); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if got := string(tx.args[4].([]byte)); got != "null" {
+		t.Fatalf("offloaded payload must not be stored inline, got %s", got)
+	}
+	var ref events.PayloadRef
+	if err := json.Unmarshal(tx.args[10].([]byte), &ref); err != nil {
+		t.Fatalf("payload_ref: %v", err)
+	}
+	data, err := blob.Load(context.Background(), blobs, ref)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !strings.Contains(string(data), strings.Repeat("x", 1024)) {
+		t.Fatalf("unexpected blob: %s", data)
+	}
+}
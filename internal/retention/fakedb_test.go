@@ -0,0 +1,102 @@
+package retention_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+)
+
+// fakeDB is a scripted database/sql driver: it records statements and transaction
+// outcomes and answers queries with respond, so the job can be tested without Postgres.
+type fakeDB struct {
+	respond func(query string, args []any) [][]driver.Value
+
+	mu        sync.Mutex
+	stmts     []fakeStmt
+	commits   int
+	rollbacks int
+}
+
+type fakeStmt struct {
+	query string
+	args  []any
+}
+
+func newFakeDB(respond func(query string, args []any) [][]driver.Value) (*fakeDB, *sql.DB) {
+	f := &fakeDB{respond: respond}
+	return f, sql.OpenDB(f)
+}
+
+// queries returns the recorded statements containing substr.
+func (f *fakeDB) queries(substr string) []fakeStmt {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []fakeStmt
+	for _, s := range f.stmts {
+		if strings.Contains(s.query, substr) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return fakeConn{f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return fakeDriver{f} }
+
+type fakeDriver struct{ f *fakeDB }
+
+func (d fakeDriver) Open(string) (driver.Conn, error) { return fakeConn(d), nil }
+
+type fakeConn struct{ f *fakeDB }
+
+func (c fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fakedb: prepared statements are not supported")
+}
+func (c fakeConn) Close() error              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error) { return fakeTx(c), nil }
+
+func (c fakeConn) QueryContext(_ context.Context, query string, named []driver.NamedValue) (driver.Rows, error) {
+	args := make([]any, len(named))
+	for i, a := range named {
+		args[i] = a.Value
+	}
+	c.f.mu.Lock()
+	c.f.stmts = append(c.f.stmts, fakeStmt{query: query, args: args})
+	c.f.mu.Unlock()
+	return &fakeRows{rows: c.f.respond(query, args)}, nil
+}
+
+type fakeTx struct{ f *fakeDB }
+
+func (t fakeTx) Commit() error {
+	t.f.mu.Lock()
+	defer t.f.mu.Unlock()
+	t.f.commits++
+	return nil
+}
+
+func (t fakeTx) Rollback() error {
+	t.f.mu.Lock()
+	defer t.f.mu.Unlock()
+	t.f.rollbacks++
+	return nil
+}
+
+// fakeRows yields single-column rows.
+type fakeRows struct{ rows [][]driver.Value }
+
+func (r *fakeRows) Columns() []string { return []string{"v"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
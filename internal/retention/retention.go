@@ -0,0 +1,253 @@
+package retention
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Target describes a table cleaned by a Job. Key lists the primary key columns
+// (comma-separated); Where is a predicate with $1 = cutoff time.
+type Target struct {
+	Table string
+	Key   string
+	Where string
+}
+
+var (
+	Outbox = Target{
+		Table: "outbox",
+		Key:   "id, created_at", // partitioned: id alone is not unique across partitions
+		Where: "(status = 'sent' AND sent_at < $1) OR (status = 'cancelled' AND updated_at < $1)",
+	}
+	ProcessedEvents = Target{
+		Table: "processed_events",
+		Key:   "event_id",
+		Where: "status = 'done' AND processed_at < $1",
+	}
+)
+
+type Config struct {
+	// MaxAge is how long finished rows are kept. Zero disables the job.
+	MaxAge    time.Duration
+	Interval  time.Duration
+	BatchSize int
+	// ArchiveDir, when set, receives an NDJSON.gz file per batch before the rows are deleted.
+	ArchiveDir string
+	// Active, if set, gates each run, e.g. to the shard-0 owner among relay replicas.
+	Active func() bool
+}
+
+type Metrics struct {
+	DeletedTotal  *prometheus.CounterVec
+	ArchivedTotal *prometheus.CounterVec
+	ErrorsTotal   *prometheus.CounterVec
+}
+
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		DeletedTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{Name: "retention_deleted_total", Help: "Rows deleted by the retention job."},
+			[]string{"table"},
+		),
+		ArchivedTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{Name: "retention_archived_total", Help: "Rows written to the retention archive before deletion."},
+			[]string{"table"},
+		),
+		ErrorsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{Name: "retention_errors_total", Help: "Failed retention batches."},
+			[]string{"table"},
+		),
+	}
+	reg.MustRegister(m.DeletedTotal, m.ArchivedTotal, m.ErrorsTotal)
+	return m
+}
+
+// Job deletes (and optionally archives) old rows of one Target in bounded batches.
+//
+// Each batch is its own transaction guarded by pg_try_advisory_xact_lock, so with
+// several replicas only one works on a table at a time; the rest skip the run.
+// Archive files are fully written and fsynced before the DELETE commits: a crash in
+// between may leave rows archived twice, never deleted without an archive.
+type Job struct {
+	db     *sql.DB
+	target Target
+	cfg    Config
+	log    *slog.Logger
+	met    *Metrics
+	seq    int
+}
+
+func NewJob(db *sql.DB, target Target, cfg Config, log *slog.Logger, met *Metrics) *Job {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 10 * time.Minute
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 1000
+	}
+	for _, vec := range []*prometheus.CounterVec{met.DeletedTotal, met.ArchivedTotal, met.ErrorsTotal} {
+		vec.WithLabelValues(target.Table).Add(0)
+	}
+	return &Job{db: db, target: target, cfg: cfg, log: log, met: met}
+}
+
+// Run blocks until ctx is cancelled, calling RunOnce every Interval.
+func (j *Job) Run(ctx context.Context) {
+	if j.cfg.MaxAge <= 0 {
+		return
+	}
+
+	t := time.NewTicker(j.cfg.Interval)
+	defer t.Stop()
+
+	for {
+		if j.cfg.Active == nil || j.cfg.Active() {
+			if _, err := j.RunOnce(ctx); err != nil && ctx.Err() == nil {
+				j.log.Error("retention_failed", slog.String("table", j.target.Table), slog.String("err", err.Error()))
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// RunOnce processes batches until nothing older than MaxAge is left (or another
+// replica holds the lock) and returns the number of deleted rows.
+func (j *Job) RunOnce(ctx context.Context) (int64, error) {
+	cutoff := time.Now().Add(-j.cfg.MaxAge)
+
+	var total int64
+	for ctx.Err() == nil {
+		n, locked, err := j.batch(ctx, cutoff)
+		if err != nil {
+			j.met.ErrorsTotal.WithLabelValues(j.target.Table).Inc()
+			return total, err
+		}
+		total += int64(n)
+		if !locked || n < j.cfg.BatchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		j.log.Info("retention_done",
+			slog.String("table", j.target.Table),
+			slog.Int64("deleted", total),
+			slog.String("cutoff", cutoff.UTC().Format(time.RFC3339)),
+		)
+	}
+	return total, nil
+}
+
+func (j *Job) batch(ctx context.Context, cutoff time.Time) (n int, locked bool, err error) {
+	tx, err := j.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return 0, false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := tx.QueryRowContext(ctx, `SELECT pg_try_advisory_xact_lock(hashtext($1));`, "retention:"+j.target.Table).Scan(&locked); err != nil {
+		return 0, false, err
+	}
+	if !locked {
+		return 0, false, nil
+	}
+
+	returning := "1"
+	if j.cfg.ArchiveDir != "" {
+		returning = "to_jsonb(t)::text"
+	}
+	q := fmt.Sprintf(`
+DELETE FROM %[1]s t
+WHERE (%[2]s) IN (
+  SELECT %[2]s
+  FROM %[1]s
+  WHERE %[3]s
+  ORDER BY %[2]s
+  LIMIT $2
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING %[4]s;
+`, j.target.Table, j.target.Key, j.target.Where, returning)
+
+	rows, err := tx.QueryContext(ctx, q, cutoff, j.cfg.BatchSize)
+	if err != nil {
+		return 0, true, err
+	}
+	var lines []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			_ = rows.Close()
+			return 0, true, err
+		}
+		lines = append(lines, line)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, true, err
+	}
+	if len(lines) == 0 {
+		return 0, true, nil
+	}
+
+	if j.cfg.ArchiveDir != "" {
+		if err := j.archive(lines); err != nil {
+			return 0, true, fmt.Errorf("archive: %w", err)
+		}
+		j.met.ArchivedTotal.WithLabelValues(j.target.Table).Add(float64(len(lines)))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, true, err
+	}
+	j.met.DeletedTotal.WithLabelValues(j.target.Table).Add(float64(len(lines)))
+	return len(lines), true, nil
+}
+
+// archive writes lines to <dir>/<table>-<utc>-<seq>.ndjson.gz via a temp file + rename.
+func (j *Job) archive(lines []string) error {
+	if err := os.MkdirAll(j.cfg.ArchiveDir, 0o755); err != nil {
+		return err
+	}
+	j.seq++
+	name := fmt.Sprintf("%s-%s-%06d.ndjson.gz", j.target.Table, time.Now().UTC().Format("20060102T150405Z"), j.seq)
+	final := filepath.Join(j.cfg.ArchiveDir, name)
+	tmp := final + ".tmp"
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp) }()
+
+	zw := gzip.NewWriter(f)
+	for _, line := range lines {
+		if _, err := zw.Write([]byte(line + "\n")); err != nil {
+			_ = f.Close()
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, final)
+}
@@ -0,0 +1,157 @@
+package retention_test
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/k1networth/servicedesk-lite/internal/retention"
+)
+
+// outboxTable answers the lock query with locked and each DELETE with the next batch.
+func outboxTable(locked bool, batches ...[]string) func(string, []any) [][]driver.Value {
+	return func(q string, _ []any) [][]driver.Value {
+		if strings.Contains(q, "pg_try_advisory_xact_lock") {
+			return [][]driver.Value{{locked}}
+		}
+		if len(batches) == 0 {
+			return nil
+		}
+		var rows [][]driver.Value
+		for _, line := range batches[0] {
+			rows = append(rows, []driver.Value{line})
+		}
+		batches = batches[1:]
+		return rows
+	}
+}
+
+func newJob(t *testing.T, respond func(string, []any) [][]driver.Value, cfg retention.Config) (*fakeDB, *retention.Job, *retention.Metrics) {
+	t.Helper()
+	f, db := newFakeDB(respond)
+	t.Cleanup(func() { _ = db.Close() })
+	cfg.MaxAge = time.Hour
+	met := retention.NewMetrics(prometheus.NewRegistry())
+	return f, retention.NewJob(db, retention.Outbox, cfg, slog.New(slog.NewTextHandler(io.Discard, nil)), met), met
+}
+
+func TestRunOnceDeletesInBoundedBatches(t *testing.T) {
+	f, job, met := newJob(t, outboxTable(true, []string{"1", "1"}, []string{"1", "1"}, []string{"1"}), retention.Config{BatchSize: 2})
+
+	n, err := job.RunOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 5 {
+		t.Fatalf("deleted %d rows, want 5", n)
+	}
+	deletes := f.queries("DELETE FROM outbox")
+	if len(deletes) != 3 || f.commits != 3 {
+		t.Fatalf("expected 3 batches, each committed; got %d deletes, %d commits", len(deletes), f.commits)
+	}
+	for _, d := range deletes {
+		if !strings.Contains(d.query, "LIMIT $2") || d.args[1] != int64(2) {
+			t.Fatalf("DELETE not bounded by the batch size: %v %s", d.args, d.query)
+		}
+	}
+	if got := testutil.ToFloat64(met.DeletedTotal.WithLabelValues("outbox")); got != 5 {
+		t.Fatalf("retention_deleted_total = %v, want 5", got)
+	}
+}
+
+func TestOutboxDeleteMatchesOnPartitionKey(t *testing.T) {
+	f, job, _ := newJob(t, outboxTable(true, []string{"1"}), retention.Config{BatchSize: 2})
+
+	if _, err := job.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// outbox ids are only unique together with created_at, the partition key.
+	d := f.queries("DELETE FROM outbox")
+	if len(d) != 1 || !strings.Contains(d[0].query, "(id, created_at) IN (") || !strings.Contains(d[0].query, "ORDER BY id, created_at") {
+		t.Fatalf("DELETE must match rows on (id, created_at): %v", d)
+	}
+}
+
+func TestRunOnceSkipsWhenAnotherReplicaHoldsTheLock(t *testing.T) {
+	f, job, _ := newJob(t, outboxTable(false, []string{"1"}), retention.Config{BatchSize: 2})
+
+	n, err := job.RunOnce(context.Background())
+	if err != nil || n != 0 {
+		t.Fatalf("got %d, %v; want 0, nil", n, err)
+	}
+	if d := f.queries("DELETE"); len(d) != 0 || f.commits != 0 {
+		t.Fatalf("expected no delete without the lock, got %d deletes, %d commits", len(d), f.commits)
+	}
+}
+
+func TestRunOnceArchivesBeforeDeleting(t *testing.T) {
+	dir := t.TempDir()
+	lines := []string{`{"id": 1, "status": "sent"}`, `{"id": 2, "status": "cancelled"}`}
+	f, job, met := newJob(t, outboxTable(true, lines), retention.Config{BatchSize: 10, ArchiveDir: dir})
+
+	if _, err := job.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if d := f.queries("RETURNING to_jsonb(t)::text"); len(d) != 1 {
+		t.Fatal("expected the DELETE to return whole rows for the archive")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || !strings.HasPrefix(entries[0].Name(), "outbox-") || !strings.HasSuffix(entries[0].Name(), "-000001.ndjson.gz") {
+		t.Fatalf("expected one archive file and no temp file, got %v", entries)
+	}
+	file, err := os.Open(filepath.Join(dir, entries[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = file.Close() }()
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for sc := bufio.NewScanner(zr); sc.Scan(); {
+		got = append(got, sc.Text())
+	}
+	if fmt.Sprint(got) != fmt.Sprint(lines) {
+		t.Fatalf("archive content: got %q, want %q", got, lines)
+	}
+	if got := testutil.ToFloat64(met.ArchivedTotal.WithLabelValues("outbox")); got != 2 {
+		t.Fatalf("retention_archived_total = %v, want 2", got)
+	}
+}
+
+func TestRunOnceKeepsRowsWhenArchiveFails(t *testing.T) {
+	// A regular file where the archive directory should be: MkdirAll fails.
+	dir := filepath.Join(t.TempDir(), "archive")
+	if err := os.WriteFile(dir, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, job, met := newJob(t, outboxTable(true, []string{`{"id": 1}`}), retention.Config{BatchSize: 10, ArchiveDir: dir})
+
+	n, err := job.RunOnce(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "archive") {
+		t.Fatalf("expected an archive error, got %v", err)
+	}
+	if n != 0 || f.commits != 0 || f.rollbacks == 0 {
+		t.Fatalf("the DELETE must be rolled back: deleted %d, %d commits, %d rollbacks", n, f.commits, f.rollbacks)
+	}
+	if testutil.ToFloat64(met.DeletedTotal.WithLabelValues("outbox")) != 0 || testutil.ToFloat64(met.ErrorsTotal.WithLabelValues("outbox")) != 1 {
+		t.Fatal("unexpected metrics after a failed archive")
+	}
+}
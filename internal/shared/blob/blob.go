@@ -0,0 +1,50 @@
+// Package blob stores event payloads too large for the outbox table and the broker
+// (claim-check pattern): the envelope carries an events.PayloadRef instead of the payload.
+package blob
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/k1networth/servicedesk-lite/internal/shared/events"
+)
+
+// Store keeps blobs addressed by an opaque URI returned from Put. Get returns an error
+// wrapping ErrNotFound when the blob does not exist or the URI is not served by the store.
+type Store interface {
+	Put(ctx context.Context, data []byte) (uri string, err error)
+	Get(ctx context.Context, uri string) ([]byte, error)
+}
+
+var (
+	// ErrChecksum means the fetched blob does not match the reference.
+	ErrChecksum = errors.New("blob: checksum mismatch")
+	// ErrNotFound means the store has no blob for the URI.
+	ErrNotFound = errors.New("blob: not found")
+)
+
+// Offload puts payload into s and returns its reference.
+func Offload(ctx context.Context, s Store, payload []byte) (events.PayloadRef, error) {
+	uri, err := s.Put(ctx, payload)
+	if err != nil {
+		return events.PayloadRef{}, err
+	}
+	sum := sha256.Sum256(payload)
+	return events.PayloadRef{URI: uri, SHA256: hex.EncodeToString(sum[:]), Size: int64(len(payload))}, nil
+}
+
+// Load fetches the payload of ref from s and verifies its size and SHA-256.
+func Load(ctx context.Context, s Store, ref events.PayloadRef) ([]byte, error) {
+	data, err := s.Get(ctx, ref.URI)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	if int64(len(data)) != ref.Size || hex.EncodeToString(sum[:]) != ref.SHA256 {
+		return nil, fmt.Errorf("%w: %s", ErrChecksum, ref.URI)
+	}
+	return data, nil
+}
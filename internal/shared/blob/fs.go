@@ -0,0 +1,85 @@
+package blob
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const fsScheme = "fs:"
+
+var fsKeyRe = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// FSStore keeps blobs as files under a directory, content-addressed by SHA-256
+// (<dir>/<aa>/<sha256>). URIs are "fs:<sha256>", so producer and consumer may mount the
+// same volume at different paths. Writing the same payload twice is a no-op.
+type FSStore struct {
+	dir string
+}
+
+func NewFSStore(dir string) (*FSStore, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("blob: empty directory")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FSStore{dir: dir}, nil
+}
+
+func (s *FSStore) Put(_ context.Context, data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	key := hex.EncodeToString(sum[:])
+	path := s.path(key)
+	if _, err := os.Stat(path); err == nil {
+		return fsScheme + key, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	// Write to a temp file and rename, so readers never see a partial blob.
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return "", err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+	return fsScheme + key, nil
+}
+
+func (s *FSStore) Get(_ context.Context, uri string) ([]byte, error) {
+	key, ok := strings.CutPrefix(uri, fsScheme)
+	if !ok || !fsKeyRe.MatchString(key) {
+		return nil, fmt.Errorf("%w: unsupported uri %q", ErrNotFound, uri)
+	}
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, uri)
+	}
+	return data, err
+}
+
+func (s *FSStore) path(key string) string {
+	return filepath.Join(s.dir, key[:2], key)
+}
@@ -0,0 +1,65 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestOffloadAndLoadRoundTrip(t *testing.T) {
+	s, err := NewFSStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	payload := []byte(`{"ticket_id":"t-1","attachments":"` + strings.Repeat("x", 4096) + `"}`)
+
+	ref, err := Offload(context.Background(), s, payload)
+	if err != nil {
+		t.Fatalf("offload: %v", err)
+	}
+	if !strings.HasPrefix(ref.URI, "fs:") || ref.Size != int64(len(payload)) || len(ref.SHA256) != 64 {
+		t.Fatalf("unexpected ref: %+v", ref)
+	}
+
+	got, err := Load(context.Background(), s, ref)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if string(got) != string(payload) {
+		t.Fatalf("payload changed in the store")
+	}
+
+	again, err := Offload(context.Background(), s, payload)
+	if err != nil || again != ref {
+		t.Fatalf("expected the same ref for the same payload, got %+v, %v", again, err)
+	}
+}
+
+func TestLoadDetectsTampering(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := NewFSStore(dir)
+	ref, err := Offload(context.Background(), s, []byte(`{"a":1}`))
+	if err != nil {
+		t.Fatalf("offload: %v", err)
+	}
+	key := strings.TrimPrefix(ref.URI, "fs:")
+	if err := os.WriteFile(s.path(key), []byte(`{"a":2}`), 0o644); err != nil {
+		t.Fatalf("tamper: %v", err)
+	}
+
+	if _, err := Load(context.Background(), s, ref); !errors.Is(err, ErrChecksum) {
+		t.Fatalf("expected ErrChecksum, got %v", err)
+	}
+}
+
+func TestFSStoreRejectsForeignURIs(t *testing.T) {
+	s, _ := NewFSStore(t.TempDir())
+	missing := "fs:" + strings.Repeat("0", 64)
+	for _, uri := range []string{"s3://bucket/key", "fs:../../etc/passwd", "fs:", missing} {
+		if _, err := s.Get(context.Background(), uri); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("%q: expected ErrNotFound, got %v", uri, err)
+		}
+	}
+}
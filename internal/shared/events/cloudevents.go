@@ -0,0 +1,216 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Wire formats of outbox-relay (OUTBOX_RELAY_EVENT_FORMAT).
+const (
+	FormatEnvelope              = "envelope"
+	FormatCloudEventsStructured = "cloudevents-structured"
+	FormatCloudEventsBinary     = "cloudevents-binary"
+)
+
+const (
+	CloudEventsSpecVersion = "1.0"
+	ContentTypeCloudEvents = "application/cloudevents+json"
+
+	// CloudEventsSourcePrefix + aggregate is the CloudEvents source, e.g. "/servicedesk-lite/ticket".
+	CloudEventsSourcePrefix = "/servicedesk-lite/"
+)
+
+// Kafka protocol binding, binary mode: attributes travel as ce_-prefixed headers.
+const (
+	CEHeaderSpecVersion   = "ce_specversion"
+	CEHeaderID            = "ce_id"
+	CEHeaderSource        = "ce_source"
+	CEHeaderType          = "ce_type"
+	CEHeaderSubject       = "ce_subject"
+	CEHeaderTime          = "ce_time"
+	CEHeaderRequestID     = "ce_requestid"
+	CEHeaderTenantID      = "ce_tenantid"
+	CEHeaderSchemaVersion = "ce_schemaversion"
+	CEHeaderDataRef       = "ce_dataref"
+	CEHeaderDataSHA256    = "ce_datasha256"
+	CEHeaderDataSize      = "ce_datasize"
+)
+
+// CloudEvent is a CloudEvents 1.0 event in structured JSON mode.
+//
+// Mapping from Envelope: id = event_id, type = event_type, source = "/servicedesk-lite/" +
+// aggregate, subject = aggregate_id, time = occurred_at, data = payload; request_id,
+// tenant_id and schema_version are carried in the "requestid", "tenantid" and
+// "schemaversion" extensions. An offloaded payload (payload_ref) uses the "dataref"
+// extension (CloudEvents claim check) plus "datasha256" and "datasize".
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+	RequestID       string          `json:"requestid,omitempty"`
+	TenantID        string          `json:"tenantid,omitempty"`
+	SchemaVersion   int             `json:"schemaversion,omitempty"`
+	DataRef         string          `json:"dataref,omitempty"`
+	DataSHA256      string          `json:"datasha256,omitempty"`
+	DataSize        int64           `json:"datasize,omitempty"`
+}
+
+func ToCloudEvent(env Envelope) CloudEvent {
+	ce := CloudEvent{
+		SpecVersion:     CloudEventsSpecVersion,
+		ID:              env.EventID,
+		Source:          CloudEventsSourcePrefix + env.Aggregate,
+		Type:            env.EventType,
+		Subject:         env.AggregateID,
+		Time:            env.OccurredAt,
+		DataContentType: ContentTypeJSON,
+		Data:            env.Payload,
+		RequestID:       env.RequestID,
+		TenantID:        env.TenantID,
+		SchemaVersion:   env.SchemaVersion,
+	}
+	if ref := env.PayloadRef; ref != nil {
+		ce.Data = nil
+		ce.DataRef, ce.DataSHA256, ce.DataSize = ref.URI, ref.SHA256, ref.Size
+	}
+	return ce
+}
+
+// Envelope converts back; the aggregate is the last segment of source.
+func (ce CloudEvent) Envelope() (Envelope, error) {
+	if ce.SpecVersion != CloudEventsSpecVersion {
+		return Envelope{}, fmt.Errorf("cloudevents: unsupported specversion %q", ce.SpecVersion)
+	}
+	if ce.ID == "" || ce.Type == "" || ce.Source == "" {
+		return Envelope{}, errors.New("cloudevents: id, type and source are required")
+	}
+	env := Envelope{
+		EventID:       ce.ID,
+		EventType:     ce.Type,
+		SchemaVersion: ce.SchemaVersion,
+		OccurredAt:    ce.Time,
+		Aggregate:     path.Base(ce.Source),
+		AggregateID:   ce.Subject,
+		RequestID:     ce.RequestID,
+		TenantID:      ce.TenantID,
+		Payload:       ce.Data,
+	}
+	if ce.DataRef != "" {
+		env.Payload = nil
+		env.PayloadRef = &PayloadRef{URI: ce.DataRef, SHA256: ce.DataSHA256, Size: ce.DataSize}
+	}
+	return env, nil
+}
+
+// BinaryHeaders returns the ce_* headers for binary mode; the body is ce.Data.
+func (ce CloudEvent) BinaryHeaders() [][2]string {
+	hs := [][2]string{
+		{CEHeaderSpecVersion, ce.SpecVersion},
+		{CEHeaderID, ce.ID},
+		{CEHeaderSource, ce.Source},
+		{CEHeaderType, ce.Type},
+		{CEHeaderTime, ce.Time.UTC().Format(time.RFC3339Nano)},
+	}
+	if ce.Subject != "" {
+		hs = append(hs, [2]string{CEHeaderSubject, ce.Subject})
+	}
+	if ce.RequestID != "" {
+		hs = append(hs, [2]string{CEHeaderRequestID, ce.RequestID})
+	}
+	if ce.TenantID != "" {
+		hs = append(hs, [2]string{CEHeaderTenantID, ce.TenantID})
+	}
+	if ce.SchemaVersion > 0 {
+		hs = append(hs, [2]string{CEHeaderSchemaVersion, strconv.Itoa(ce.SchemaVersion)})
+	}
+	if ce.DataRef != "" {
+		hs = append(hs,
+			[2]string{CEHeaderDataRef, ce.DataRef},
+			[2]string{CEHeaderDataSHA256, ce.DataSHA256},
+			[2]string{CEHeaderDataSize, strconv.FormatInt(ce.DataSize, 10)},
+		)
+	}
+	return hs
+}
+
+// Decode reads a message in any format the relay can emit and returns it as an Envelope:
+// binary CloudEvents (ce_specversion header), structured CloudEvents (JSON with
+// "specversion"), or the legacy envelope. header looks up a message header.
+func Decode(value []byte, header func(key string) (string, bool)) (Envelope, error) {
+	if header != nil {
+		if sv, ok := header(CEHeaderSpecVersion); ok {
+			return decodeBinary(sv, value, header)
+		}
+	}
+
+	var probe struct {
+		SpecVersion *string `json:"specversion"`
+	}
+	if err := json.Unmarshal(value, &probe); err != nil {
+		return Envelope{}, err
+	}
+	if probe.SpecVersion != nil {
+		var ce CloudEvent
+		if err := json.Unmarshal(value, &ce); err != nil {
+			return Envelope{}, err
+		}
+		return ce.Envelope()
+	}
+
+	var env Envelope
+	err := json.Unmarshal(value, &env)
+	return env, err
+}
+
+func decodeBinary(specVersion string, value []byte, header func(string) (string, bool)) (Envelope, error) {
+	get := func(k string) string {
+		v, _ := header(k)
+		return v
+	}
+	ce := CloudEvent{
+		SpecVersion: specVersion,
+		ID:          get(CEHeaderID),
+		Source:      get(CEHeaderSource),
+		Type:        get(CEHeaderType),
+		Subject:     get(CEHeaderSubject),
+		RequestID:   get(CEHeaderRequestID),
+		TenantID:    get(CEHeaderTenantID),
+		Data:        value,
+	}
+	if ct, ok := header(HeaderContentType); ok && !strings.HasPrefix(ct, ContentTypeJSON) {
+		return Envelope{}, fmt.Errorf("cloudevents: unsupported content-type %q", ct)
+	}
+	if ts := get(CEHeaderTime); ts != "" {
+		t, err := time.Parse(time.RFC3339Nano, ts)
+		if err != nil {
+			return Envelope{}, fmt.Errorf("cloudevents: bad ce_time: %w", err)
+		}
+		ce.Time = t
+	}
+	if sv := get(CEHeaderSchemaVersion); sv != "" {
+		v, err := strconv.Atoi(sv)
+		if err != nil {
+			return Envelope{}, fmt.Errorf("cloudevents: bad ce_schemaversion: %w", err)
+		}
+		ce.SchemaVersion = v
+	}
+	if ref := get(CEHeaderDataRef); ref != "" {
+		size, err := strconv.ParseInt(get(CEHeaderDataSize), 10, 64)
+		if err != nil {
+			return Envelope{}, fmt.Errorf("cloudevents: bad ce_datasize: %w", err)
+		}
+		ce.Data = nil
+		ce.DataRef, ce.DataSHA256, ce.DataSize = ref, get(CEHeaderDataSHA256), size
+	}
+	return ce.Envelope()
+}
@@ -0,0 +1,83 @@
+package events
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func testEnvelope() Envelope {
+	return Envelope{
+		EventID:     "0b6f2f9e-6c1e-4b7e-9f5e-4c8f1f0d2a11",
+		EventType:   EventTypeTicketCreated,
+		OccurredAt:  time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC),
+		Aggregate:   "ticket",
+		AggregateID: "42",
+		RequestID:   "req-1",
+		Payload:     json.RawMessage(`{"ticket_id":"42"}`),
+	}
+}
+
+func headerFunc(hs [][2]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		for _, h := range hs {
+			if h[0] == key {
+				return h[1], true
+			}
+		}
+		return "", false
+	}
+}
+
+func assertSameEnvelope(t *testing.T, got, want Envelope) {
+	t.Helper()
+	if got.EventID != want.EventID || got.EventType != want.EventType || !got.OccurredAt.Equal(want.OccurredAt) ||
+		got.Aggregate != want.Aggregate || got.AggregateID != want.AggregateID || got.RequestID != want.RequestID ||
+		string(got.Payload) != string(want.Payload) {
+		t.Fatalf("envelope mismatch:\n got  %+v\n want %+v", got, want)
+	}
+}
+
+func TestDecodeLegacyEnvelope(t *testing.T) {
+	want := testEnvelope()
+	b, _ := json.Marshal(want)
+
+	got, err := Decode(b, nil)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	assertSameEnvelope(t, got, want)
+}
+
+func TestDecodeStructuredCloudEvent(t *testing.T) {
+	want := testEnvelope()
+	ce := ToCloudEvent(want)
+	if ce.Source != "/servicedesk-lite/ticket" || ce.Subject != "42" {
+		t.Fatalf("unexpected mapping: %+v", ce)
+	}
+	b, _ := json.Marshal(ce)
+
+	got, err := Decode(b, headerFunc(nil))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	assertSameEnvelope(t, got, want)
+}
+
+func TestDecodeBinaryCloudEvent(t *testing.T) {
+	want := testEnvelope()
+	ce := ToCloudEvent(want)
+	hs := append(ce.BinaryHeaders(), [2]string{HeaderContentType, ContentTypeJSON})
+
+	got, err := Decode(ce.Data, headerFunc(hs))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	assertSameEnvelope(t, got, want)
+}
+
+func TestDecodeRejectsIncompleteCloudEvent(t *testing.T) {
+	if _, err := Decode([]byte(`{"specversion":"1.0","type":"ticket.created"}`), nil); err == nil {
+		t.Fatalf("expected missing id/source to be rejected")
+	}
+}
@@ -5,14 +5,31 @@ import (
 	"time"
 )
 
-const EventTypeTicketCreated = "ticket.created"
+const (
+	EventTypeTicketCreated      = "ticket.created"
+	EventTypeTicketWorklogAdded = "ticket.worklog_added"
+)
 
 type Envelope struct {
-	EventID     string          `json:"event_id"`
-	EventType   string          `json:"event_type"`
-	OccurredAt  time.Time       `json:"occurred_at"`
-	Aggregate   string          `json:"aggregate"`
-	AggregateID string          `json:"aggregate_id"`
-	RequestID   string          `json:"request_id,omitempty"`
-	Payload     json.RawMessage `json:"payload"`
+	EventID   string `json:"event_id"`
+	EventType string `json:"event_type"`
+	// SchemaVersion is the payload version (schemas/<event_type>.v<N>.json); 0 in
+	// messages from relays that predate versioning and means 1.
+	SchemaVersion int             `json:"schema_version,omitempty"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	Aggregate     string          `json:"aggregate"`
+	AggregateID   string          `json:"aggregate_id"`
+	RequestID     string          `json:"request_id,omitempty"`
+	TenantID      string          `json:"tenant_id,omitempty"`
+	Payload       json.RawMessage `json:"payload"`
+	// PayloadRef is set instead of Payload when the payload was offloaded to a blob store
+	// (claim check); consumers fetch and verify it with blob.Load.
+	PayloadRef *PayloadRef `json:"payload_ref,omitempty"`
+}
+
+// PayloadRef points to an offloaded payload.
+type PayloadRef struct {
+	URI    string `json:"uri"`
+	SHA256 string `json:"sha256"` // hex
+	Size   int64  `json:"size"`
 }
@@ -0,0 +1,37 @@
+package events
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+)
+
+// Transport headers set by outbox-relay on every message. They duplicate envelope fields
+// so consumers can route or skip a message without decoding the body.
+const (
+	HeaderEventID       = "event-id"
+	HeaderEventType     = "event-type"
+	HeaderSchemaVersion = "schema-version"
+	HeaderRequestID     = "request-id"
+	HeaderTenantID      = "tenant-id"
+	HeaderTraceparent   = "traceparent"
+	HeaderContentType   = "content-type"
+)
+
+const ContentTypeJSON = "application/json"
+
+var traceparentRe = regexp.MustCompile(`^00-[0-9a-f]{32}-[0-9a-f]{16}-[0-9a-f]{2}$`)
+
+// ValidTraceparent reports whether s is a W3C trace context traceparent (version 00).
+func ValidTraceparent(s string) bool {
+	return traceparentRe.MatchString(s) &&
+		s[3:35] != "00000000000000000000000000000000" &&
+		s[36:52] != "0000000000000000"
+}
+
+// NewTraceparent starts a new sampled trace.
+func NewTraceparent() string {
+	var b [24]byte
+	_, _ = rand.Read(b[:])
+	return "00-" + hex.EncodeToString(b[:16]) + "-" + hex.EncodeToString(b[16:]) + "-01"
+}
@@ -0,0 +1,147 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// jsonSchema is the subset of JSON Schema used by schemas/*.json: type (single or list),
+// required, properties, additionalProperties (bool), items, enum, minLength, minimum and
+// format "date-time". Unknown keywords are ignored; keeping schemas inside the subset is
+// checked by tests.
+type jsonSchema struct {
+	Type                 schemaTypes            `json:"type"`
+	Required             []string               `json:"required"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	AdditionalProperties *bool                  `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+	Enum                 []any                  `json:"enum"`
+	MinLength            *int                   `json:"minLength"`
+	Minimum              *float64               `json:"minimum"`
+	Format               string                 `json:"format"`
+}
+
+// schemaTypes accepts both "type": "string" and "type": ["string", "null"].
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(b []byte) error {
+	var one string
+	if err := json.Unmarshal(b, &one); err == nil {
+		*t = schemaTypes{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return err
+	}
+	*t = many
+	return nil
+}
+
+func (s *jsonSchema) validate(doc []byte) error {
+	var v any
+	dec := json.NewDecoder(strings.NewReader(string(doc)))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	return s.check("$", v)
+}
+
+func (s *jsonSchema) check(path string, v any) error {
+	if len(s.Type) > 0 && !slices.ContainsFunc(s.Type, func(t string) bool { return hasType(v, t) }) {
+		return fmt.Errorf("%s: expected %s, got %s", path, strings.Join(s.Type, " or "), typeOf(v))
+	}
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(e any) bool { return fmt.Sprint(e) == fmt.Sprint(v) }) {
+		return fmt.Errorf("%s: %v is not one of %v", path, v, s.Enum)
+	}
+
+	switch x := v.(type) {
+	case string:
+		if s.MinLength != nil && len([]rune(x)) < *s.MinLength {
+			return fmt.Errorf("%s: shorter than %d", path, *s.MinLength)
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, x); err != nil {
+				return fmt.Errorf("%s: not a date-time: %q", path, x)
+			}
+		}
+	case json.Number:
+		if s.Minimum != nil {
+			f, _ := x.Float64()
+			if f < *s.Minimum {
+				return fmt.Errorf("%s: %s is less than %v", path, x, *s.Minimum)
+			}
+		}
+	case []any:
+		if s.Items != nil {
+			for i, item := range x {
+				if err := s.Items.check(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+					return err
+				}
+			}
+		}
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := x[name]; !ok {
+				return fmt.Errorf("%s: missing required property %q", path, name)
+			}
+		}
+		names := make([]string, 0, len(x))
+		for name := range x {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return fmt.Errorf("%s: unexpected property %q", path, name)
+				}
+				continue
+			}
+			if err := prop.check(path+"."+name, x[name]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func hasType(v any, t string) bool {
+	switch t {
+	case "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			return false
+		}
+		f, err := n.Float64()
+		return err == nil && f == math.Trunc(f)
+	default:
+		return typeOf(v) == t
+	}
+}
+
+func typeOf(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
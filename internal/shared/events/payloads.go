@@ -0,0 +1,44 @@
+package events
+
+import "time"
+
+// Typed payloads, one struct per event type and schema version. Producers build the
+// current version; consumers get it from Upcast whatever version was published.
+// Each struct must match its schemas/<type>.v<N>.json (checked by tests).
+
+// TicketCreatedV1 is the ticket.created payload before requester_org was added.
+type TicketCreatedV1 struct {
+	TicketID    string    `json:"ticket_id"`
+	Title       string    `json:"title"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+	RequestID   string    `json:"request_id,omitempty"`
+	Traceparent string    `json:"traceparent,omitempty"`
+}
+
+type TicketCreatedV2 struct {
+	TicketID     string    `json:"ticket_id"`
+	Title        string    `json:"title"`
+	Status       string    `json:"status"`
+	RequesterOrg string    `json:"requester_org"`
+	CreatedAt    time.Time `json:"created_at"`
+	RequestID    string    `json:"request_id,omitempty"`
+	Traceparent  string    `json:"traceparent,omitempty"`
+}
+
+type TicketWorklogAddedV1 struct {
+	TicketID        string    `json:"ticket_id"`
+	WorklogID       int64     `json:"worklog_id"`
+	Agent           string    `json:"agent"`
+	DurationMinutes int       `json:"duration_minutes"`
+	Billable        bool      `json:"billable"`
+	CreatedAt       time.Time `json:"created_at"`
+	RequestID       string    `json:"request_id,omitempty"`
+	Traceparent     string    `json:"traceparent,omitempty"`
+}
+
+// Current payload versions; bump together with a new schema file and an upcaster.
+type (
+	TicketCreated      = TicketCreatedV2
+	TicketWorklogAdded = TicketWorklogAddedV1
+)
@@ -0,0 +1,176 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// samples holds a filled-in typed payload for every registered schema. Adding a schema
+// file without its struct here fails TestEverySchemaHasTypedPayload.
+var samples = map[schemaKey]any{
+	{EventTypeTicketCreated, 1}: TicketCreatedV1{
+		TicketID: "t-1", Title: "VPN is down", Status: "new",
+		CreatedAt: time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC), RequestID: "req-1",
+	},
+	{EventTypeTicketCreated, 2}: TicketCreatedV2{
+		TicketID: "t-1", Title: "VPN is down", Status: "new", RequesterOrg: "acme",
+		CreatedAt: time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC), RequestID: "req-1",
+	},
+	{EventTypeTicketWorklogAdded, 1}: TicketWorklogAddedV1{
+		TicketID: "t-1", WorklogID: 7, Agent: "alice", DurationMinutes: 30, Billable: true,
+		CreatedAt: time.Date(2026, 3, 1, 11, 0, 0, 0, time.UTC),
+	},
+}
+
+// supportedKeywords must cover every keyword used in schemas/*.json, otherwise the
+// validator would silently ignore a constraint.
+var supportedKeywords = []string{
+	"$schema", "title", "description", "type", "required", "properties",
+	"additionalProperties", "items", "enum", "minLength", "minimum", "format",
+}
+
+func TestEverySchemaHasTypedPayload(t *testing.T) {
+	for key := range schemas {
+		if _, ok := samples[key]; !ok {
+			t.Errorf("schema %s v%d has no typed payload sample", key.eventType, key.version)
+		}
+	}
+	for key := range samples {
+		if _, ok := schemas[key]; !ok {
+			t.Errorf("sample %s v%d has no schema", key.eventType, key.version)
+		}
+	}
+}
+
+func TestSchemasUseSupportedKeywords(t *testing.T) {
+	for key := range schemas {
+		b, err := schemaFiles.ReadFile("schemas/" + key.eventType + ".v" + strconv.Itoa(key.version) + ".json")
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		var doc any
+		if err := json.Unmarshal(b, &doc); err != nil {
+			t.Fatalf("%s: %v", key.eventType, err)
+		}
+		walkSchema(t, key.eventType, doc)
+	}
+}
+
+func walkSchema(t *testing.T, name string, doc any) {
+	m, ok := doc.(map[string]any)
+	if !ok {
+		return
+	}
+	for kw, v := range m {
+		if !slices.Contains(supportedKeywords, kw) {
+			t.Errorf("%s: unsupported keyword %q", name, kw)
+		}
+		switch kw {
+		case "properties":
+			for _, p := range v.(map[string]any) {
+				walkSchema(t, name, p)
+			}
+		case "items":
+			walkSchema(t, name, v)
+		}
+	}
+}
+
+// TestTypedPayloadsMatchSchemas keeps structs and schemas in sync: the samples validate,
+// JSON field names equal schema properties and required properties are never omitempty.
+func TestTypedPayloadsMatchSchemas(t *testing.T) {
+	for key, sample := range samples {
+		s := schemas[key]
+		b, _ := json.Marshal(sample)
+		if err := ValidatePayload(key.eventType, key.version, b); err != nil {
+			t.Errorf("%s v%d sample: %v", key.eventType, key.version, err)
+		}
+
+		fields := map[string]bool{} // json name -> omitempty
+		rt := reflect.TypeOf(sample)
+		for i := 0; i < rt.NumField(); i++ {
+			name, opts, _ := strings.Cut(rt.Field(i).Tag.Get("json"), ",")
+			fields[name] = strings.Contains(opts, "omitempty")
+		}
+		for name := range fields {
+			if _, ok := s.Properties[name]; !ok {
+				t.Errorf("%s v%d: field %q is not in the schema", key.eventType, key.version, name)
+			}
+		}
+		for name := range s.Properties {
+			if _, ok := fields[name]; !ok {
+				t.Errorf("%s v%d: schema property %q has no struct field", key.eventType, key.version, name)
+			}
+		}
+		for _, name := range s.Required {
+			if fields[name] {
+				t.Errorf("%s v%d: required %q is omitempty", key.eventType, key.version, name)
+			}
+		}
+	}
+}
+
+func TestValidatePayloadRejectsBreakingChanges(t *testing.T) {
+	cases := map[string]string{
+		"missing field":   `{"ticket_id":"t-1","title":"x","status":"new","created_at":"2026-03-01T10:00:00Z"}`,
+		"wrong type":      `{"ticket_id":1,"title":"x","status":"new","requester_org":"","created_at":"2026-03-01T10:00:00Z"}`,
+		"bad date":        `{"ticket_id":"t-1","title":"x","status":"new","requester_org":"","created_at":"yesterday"}`,
+		"unknown field":   `{"ticket_id":"t-1","title":"x","status":"new","requester_org":"","created_at":"2026-03-01T10:00:00Z","prio":1}`,
+		"empty ticket id": `{"ticket_id":"","title":"x","status":"new","requester_org":"","created_at":"2026-03-01T10:00:00Z"}`,
+	}
+	for name, payload := range cases {
+		var se *SchemaError
+		if err := ValidatePayload(EventTypeTicketCreated, 2, []byte(payload)); !errors.As(err, &se) {
+			t.Errorf("%s: expected SchemaError, got %v", name, err)
+		}
+	}
+
+	if err := ValidatePayload(EventTypeTicketWorklogAdded, 1, []byte(`{"ticket_id":"t","worklog_id":1.5,"agent":"a","duration_minutes":1,"billable":false,"created_at":"2026-03-01T10:00:00Z"}`)); err == nil {
+		t.Errorf("expected non-integer worklog_id to be rejected")
+	}
+	if err := ValidatePayload(EventTypeTicketCreated, 9, []byte(`{}`)); err == nil {
+		t.Errorf("expected unknown version to be rejected")
+	}
+	if err := ValidatePayload("billing.invoice_paid", 1, []byte(`{}`)); !errors.Is(err, ErrNoSchema) {
+		t.Errorf("expected ErrNoSchema, got %v", err)
+	}
+}
+
+func TestUpcastersReachCurrentVersion(t *testing.T) {
+	for et, cur := range latest {
+		for v := 1; v < cur; v++ {
+			b, _ := json.Marshal(samples[schemaKey{et, v}])
+			out, got, err := Upcast(et, v, b)
+			if err != nil {
+				t.Fatalf("%s v%d: %v", et, v, err)
+			}
+			if got != cur {
+				t.Fatalf("%s v%d: upcast to v%d, want v%d", et, v, got, cur)
+			}
+			if err := ValidatePayload(et, got, out); err != nil {
+				t.Fatalf("%s v%d upcast output: %v", et, v, err)
+			}
+		}
+	}
+}
+
+func TestEnvelopeUpcastTreatsMissingVersionAsV1(t *testing.T) {
+	b, _ := json.Marshal(samples[schemaKey{EventTypeTicketCreated, 1}])
+	env, err := Envelope{EventType: EventTypeTicketCreated, Payload: b}.Upcast()
+	if err != nil {
+		t.Fatalf("upcast: %v", err)
+	}
+	var p TicketCreated
+	if err := json.Unmarshal(env.Payload, &p); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if env.SchemaVersion != CurrentSchemaVersion(EventTypeTicketCreated) || p.TicketID != "t-1" || p.RequesterOrg != "" {
+		t.Fatalf("unexpected result: v%d %+v", env.SchemaVersion, p)
+	}
+}
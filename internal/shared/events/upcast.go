@@ -0,0 +1,66 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Upcaster converts a payload of version N of an event type to version N+1.
+type Upcaster func(payload json.RawMessage) (json.RawMessage, error)
+
+// upcasters is keyed by the version being converted from.
+var upcasters = map[schemaKey]Upcaster{
+	{EventTypeTicketCreated, 1}: upcastTicketCreatedV1,
+}
+
+// Upcast converts payload from version to the current version of eventType by chaining
+// upcasters, and returns the new payload and version. Payloads of unknown types, and
+// payloads already at the current version, are returned unchanged.
+func Upcast(eventType string, version int, payload json.RawMessage) (json.RawMessage, int, error) {
+	version = normalizeVersion(version)
+	cur := latest[eventType]
+	if cur == 0 {
+		return payload, version, nil
+	}
+	if version > cur {
+		return nil, version, fmt.Errorf("%s v%d is newer than supported v%d", eventType, version, cur)
+	}
+	for version < cur {
+		up, ok := upcasters[schemaKey{eventType, version}]
+		if !ok {
+			return nil, version, fmt.Errorf("no upcaster for %s v%d", eventType, version)
+		}
+		next, err := up(payload)
+		if err != nil {
+			return nil, version, fmt.Errorf("upcast %s v%d: %w", eventType, version, err)
+		}
+		payload, version = next, version+1
+	}
+	return payload, version, nil
+}
+
+// Upcast returns a copy of env with the payload converted to the current schema version.
+func (env Envelope) Upcast() (Envelope, error) {
+	payload, v, err := Upcast(env.EventType, env.SchemaVersion, env.Payload)
+	if err != nil {
+		return env, err
+	}
+	env.Payload, env.SchemaVersion = payload, v
+	return env, nil
+}
+
+// upcastTicketCreatedV1: v1 had no requester_org; consumers see it as empty.
+func upcastTicketCreatedV1(payload json.RawMessage) (json.RawMessage, error) {
+	var v1 TicketCreatedV1
+	if err := json.Unmarshal(payload, &v1); err != nil {
+		return nil, err
+	}
+	return json.Marshal(TicketCreatedV2{
+		TicketID:    v1.TicketID,
+		Title:       v1.Title,
+		Status:      v1.Status,
+		CreatedAt:   v1.CreatedAt,
+		RequestID:   v1.RequestID,
+		Traceparent: v1.Traceparent,
+	})
+}
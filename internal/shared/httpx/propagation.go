@@ -0,0 +1,30 @@
+package httpx
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/k1networth/servicedesk-lite/internal/shared/events"
+	"github.com/k1networth/servicedesk-lite/internal/shared/tenant"
+	"github.com/k1networth/servicedesk-lite/internal/shared/tracecontext"
+)
+
+const (
+	tenantHeader      = "X-Tenant-Id"
+	traceparentHeader = "traceparent"
+)
+
+// Propagation puts the caller's tenant (X-Tenant-Id) and W3C traceparent into the request
+// context, so outbox.Writer can attach them to events. An invalid traceparent is dropped.
+func Propagation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if t := strings.TrimSpace(r.Header.Get(tenantHeader)); t != "" {
+			ctx = tenant.With(ctx, t)
+		}
+		if tp := strings.TrimSpace(r.Header.Get(traceparentHeader)); events.ValidTraceparent(tp) {
+			ctx = tracecontext.With(ctx, tp)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -40,18 +40,30 @@ func NewRouter(log *slog.Logger, ticketH *ticket.Handler, readyz func(context.Co
 	})))
 
 	mux.Handle("/tickets/", WithRoute("/tickets/:id", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		id := strings.TrimPrefix(r.URL.Path, "/tickets/")
-		if id == "" || strings.Contains(id, "/") {
-			// ВАЖНО: WriteErrorR (с request_id)
-			ticket.WriteErrorR(w, r, http.StatusNotFound, "not_found", "not found")
+		id, sub, nested := strings.Cut(strings.TrimPrefix(r.URL.Path, "/tickets/"), "/")
+		switch {
+		case id == "":
+		case !nested:
+			ticketH.GetTicket(w, r, id)
+			return
+		case sub == "worklogs":
+			WithRoute("/tickets/:id/worklogs", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ticketH.Worklogs(w, r, id)
+			})).ServeHTTP(w, r)
 			return
 		}
-		ticketH.GetTicket(w, r, id)
+		// ВАЖНО: WriteErrorR (с request_id)
+		ticket.WriteErrorR(w, r, http.StatusNotFound, "not_found", "not found")
+	})))
+
+	mux.Handle("/reports/time", WithRoute("/reports/time", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ticketH.TimeReport(w, r)
 	})))
 
 	var h http.Handler = mux
 	h = met.Middleware(h)
 	h = AccessLog(log)(h)
+	h = Propagation(h)
 	h = RequestID(h)
 
 	return h
@@ -1,6 +1,7 @@
 package httpx_test
 
 import (
+	"context"
 	"io"
 	"log/slog"
 	"net/http"
@@ -88,3 +89,31 @@ func TestRequestIDPreservedIfProvided(t *testing.T) {
 		t.Fatalf("expected X-Request-Id %q, got %q", "test123", got)
 	}
 }
+
+func TestTicketRoutes(t *testing.T) {
+	log := testLogger()
+	store := ticket.NewInMemoryStore()
+	if _, err := store.Create(context.Background(), ticket.Ticket{ID: "t-1", Title: "VPN is down"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	srv := httptest.NewServer(httpx.NewRouter(log, &ticket.Handler{Log: log, Store: store}, nil))
+	t.Cleanup(srv.Close)
+
+	cases := map[string]int{
+		"/tickets/t-1":          http.StatusOK,
+		"/tickets/t-1/":         http.StatusNotFound,
+		"/tickets/t-1/worklogs": http.StatusOK,
+		"/tickets/t-1/other":    http.StatusNotFound,
+		"/tickets/":             http.StatusNotFound,
+	}
+	for path, want := range cases {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("GET %s: expected %d, got %d", path, want, resp.StatusCode)
+		}
+	}
+}
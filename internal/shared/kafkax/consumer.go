@@ -89,6 +89,17 @@ func (c *Consumer) CommitMessages(ctx context.Context, msgs ...kafka.Message) er
 	return r.CommitMessages(ctx, msgs...)
 }
 
+// HeaderValue returns the value of the first header named key (exact match), so consumers
+// can route or skip a message without decoding its body.
+func HeaderValue(msg kafka.Message, key string) (string, bool) {
+	for _, h := range msg.Headers {
+		if h.Key == key {
+			return string(h.Value), true
+		}
+	}
+	return "", false
+}
+
 // Reopen closes the underlying reader and recreates it using the original config.
 // Useful when broker metadata becomes stale (e.g., after advertised.listeners changes) or on transient network errors.
 func (c *Consumer) Reopen() {
@@ -0,0 +1,21 @@
+package kafkax
+
+import (
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestHeaderValue(t *testing.T) {
+	msg := kafka.Message{Headers: []kafka.Header{
+		{Key: "event-type", Value: []byte("ticket.created")},
+		{Key: "event-type", Value: []byte("ignored")},
+	}}
+
+	if v, ok := HeaderValue(msg, "event-type"); !ok || v != "ticket.created" {
+		t.Fatalf("expected first event-type header, got %q ok=%v", v, ok)
+	}
+	if _, ok := HeaderValue(msg, "Event-Type"); ok {
+		t.Fatalf("header keys are case-sensitive")
+	}
+}
@@ -2,6 +2,8 @@ package kafkax
 
 import (
 	"context"
+	"errors"
+	"net"
 	"strings"
 	"sync"
 	"time"
@@ -11,11 +13,27 @@ import (
 
 type Producer struct {
 	mu        sync.Mutex
-	w         *kafka.Writer
+	w         writer
 	cfg       ProducerConfig
 	lastReset time.Time
 }
 
+// writer is the subset of *kafka.Writer used by Producer (swappable in tests/benchmarks).
+type writer interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// Header is a Kafka record header.
+type Header = kafka.Header
+
+// Message is a single record for ProduceBatch.
+type Message struct {
+	Key     []byte
+	Value   []byte
+	Headers []Header
+}
+
 type ProducerConfig struct {
 	Brokers      []string
 	Topic        string
@@ -29,7 +47,7 @@ func NewProducer(cfg ProducerConfig) *Producer {
 	return p
 }
 
-func newWriter(cfg ProducerConfig) *kafka.Writer {
+func newWriter(cfg ProducerConfig) writer {
 	// kafka-go caches broker metadata; when broker addresses change (e.g. after fixing
 	// advertised.listeners), a long metadata TTL may keep clients stuck until restart.
 	// We keep TTL low to self-heal without manual restarts.
@@ -61,6 +79,59 @@ func (p *Producer) Close() error {
 }
 
 func (p *Producer) Produce(ctx context.Context, key []byte, value []byte, timeout time.Duration) error {
+	msg := kafka.Message{Key: key, Value: value}
+	if err := p.write(ctx, timeout, msg); err != nil {
+		// Self-heal common failure mode: Kafka advertised.listeners changed while
+		// this producer is running (stale metadata). Recreate writer and retry once.
+		if shouldReset(err) {
+			p.resetOnce()
+			return p.write(ctx, timeout, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// ProduceBatch writes msgs with a single WriteMessages call and returns one error
+// per message, in the same order (nil means acked). Partial failures reported by
+// the broker map to individual messages; request-level failures (timeout, dial)
+// are reported for every message. Messages failed with a reset-worthy error are
+// retried once on a fresh writer, like Produce.
+func (p *Producer) ProduceBatch(ctx context.Context, msgs []Message, timeout time.Duration) []error {
+	errs := make([]error, len(msgs))
+	if len(msgs) == 0 {
+		return errs
+	}
+
+	idx := make([]int, len(msgs))
+	km := make([]kafka.Message, len(msgs))
+	for i, m := range msgs {
+		idx[i] = i
+		km[i] = kafka.Message{Key: m.Key, Value: m.Value, Headers: m.Headers}
+	}
+	spreadErrors(p.write(ctx, timeout, km...), errs, idx)
+
+	var retryIdx []int
+	for i, err := range errs {
+		if shouldReset(err) {
+			retryIdx = append(retryIdx, i)
+		}
+	}
+	if len(retryIdx) == 0 {
+		return errs
+	}
+
+	p.resetOnce()
+	retry := make([]kafka.Message, len(retryIdx))
+	for j, i := range retryIdx {
+		retry[j] = km[i]
+		errs[i] = nil
+	}
+	spreadErrors(p.write(ctx, timeout, retry...), errs, retryIdx)
+	return errs
+}
+
+func (p *Producer) write(ctx context.Context, timeout time.Duration, msgs ...kafka.Message) error {
 	if timeout <= 0 {
 		if p.cfg.WriteTimeout > 0 {
 			timeout = p.cfg.WriteTimeout
@@ -69,28 +140,53 @@ func (p *Producer) Produce(ctx context.Context, key []byte, value []byte, timeou
 		}
 	}
 
-	write := func() error {
-		p.mu.Lock()
-		w := p.w
-		p.mu.Unlock()
-		if w == nil {
-			return context.Canceled
-		}
-		cctx, cancel := context.WithTimeout(ctx, timeout)
-		defer cancel()
-		return w.WriteMessages(cctx, kafka.Message{Key: key, Value: value})
+	p.mu.Lock()
+	w := p.w
+	p.mu.Unlock()
+	if w == nil {
+		return context.Canceled
 	}
+	cctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return w.WriteMessages(cctx, msgs...)
+}
 
-	if err := write(); err != nil {
-		// Self-heal common failure mode: Kafka advertised.listeners changed while
-		// this producer is running (stale metadata). Recreate writer and retry once.
-		if shouldReset(err) {
-			p.resetOnce()
-			return write()
+// spreadErrors maps the result of a WriteMessages call over msgs[idx[i]] onto errs.
+func spreadErrors(err error, errs []error, idx []int) {
+	if err == nil {
+		return
+	}
+	var we kafka.WriteErrors
+	if errors.As(err, &we) && len(we) == len(idx) {
+		for j, i := range idx {
+			errs[i] = we[j]
 		}
-		return err
+		return
 	}
-	return nil
+	for _, i := range idx {
+		errs[i] = err
+	}
+}
+
+// IsBrokerError reports whether err means the cluster could not take the write at all
+// (network, timeout, leadership or replication trouble) as opposed to a rejection of
+// the message itself (e.g. too large).
+func IsBrokerError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if shouldReset(err) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var ne net.Error
+	if errors.As(err, &ne) {
+		return true
+	}
+	var ke kafka.Error
+	if errors.As(err, &ke) {
+		return ke.Temporary()
+	}
+	return false
 }
 
 func shouldReset(err error) bool {
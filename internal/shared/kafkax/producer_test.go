@@ -0,0 +1,115 @@
+package kafkax
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeWriter acks after ackDelay per WriteMessages call, like a broker round trip.
+type fakeWriter struct {
+	ackDelay time.Duration
+	fail     map[string]error // by message key
+	calls    int
+}
+
+func (f *fakeWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	f.calls++
+	if f.ackDelay > 0 {
+		time.Sleep(f.ackDelay)
+	}
+	var werrs kafka.WriteErrors
+	for i, m := range msgs {
+		if err := f.fail[string(m.Key)]; err != nil {
+			if werrs == nil {
+				werrs = make(kafka.WriteErrors, len(msgs))
+			}
+			werrs[i] = err
+		}
+	}
+	if werrs != nil {
+		return werrs
+	}
+	return nil
+}
+
+func (f *fakeWriter) Close() error { return nil }
+
+func testMessages(n int) []Message {
+	msgs := make([]Message, n)
+	for i := range msgs {
+		msgs[i] = Message{Key: []byte("ticket-" + strconv.Itoa(i)), Value: []byte(`{"event_type":"ticket.created"}`)}
+	}
+	return msgs
+}
+
+func TestProduceBatchMapsPartialFailures(t *testing.T) {
+	errTooLarge := errors.New("message too large")
+	fw := &fakeWriter{fail: map[string]error{"ticket-1": errTooLarge}}
+	p := &Producer{w: fw}
+
+	errs := p.ProduceBatch(context.Background(), testMessages(3), time.Second)
+
+	if fw.calls != 1 {
+		t.Fatalf("expected 1 WriteMessages call, got %d", fw.calls)
+	}
+	if len(errs) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(errs))
+	}
+	if errs[0] != nil || errs[2] != nil {
+		t.Fatalf("expected messages 0 and 2 to succeed, got %v / %v", errs[0], errs[2])
+	}
+	if !errors.Is(errs[1], errTooLarge) {
+		t.Fatalf("expected message 1 to fail with %v, got %v", errTooLarge, errs[1])
+	}
+}
+
+func TestProduceBatchRequestErrorFailsAll(t *testing.T) {
+	p := &Producer{w: nil} // closed producer
+
+	errs := p.ProduceBatch(context.Background(), testMessages(2), time.Second)
+
+	for i, err := range errs {
+		if err == nil {
+			t.Fatalf("expected message %d to fail", i)
+		}
+	}
+}
+
+const benchBatch = 50
+
+func BenchmarkProduceSequential(b *testing.B) {
+	p := &Producer{w: &fakeWriter{ackDelay: time.Millisecond}}
+	msgs := testMessages(benchBatch)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, m := range msgs {
+			if err := p.Produce(ctx, m.Key, m.Value, time.Second); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	b.ReportMetric(float64(b.N*benchBatch)/b.Elapsed().Seconds(), "msgs/s")
+}
+
+func BenchmarkProduceBatch(b *testing.B) {
+	p := &Producer{w: &fakeWriter{ackDelay: time.Millisecond}}
+	msgs := testMessages(benchBatch)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, err := range p.ProduceBatch(ctx, msgs, time.Second) {
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	b.ReportMetric(float64(b.N*benchBatch)/b.Elapsed().Seconds(), "msgs/s")
+}
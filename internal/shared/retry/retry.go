@@ -0,0 +1,229 @@
+package retry
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy computes the delay before retry number attempt (1-based: attempt 1 is the
+// delay after the first failure). prev is the previous delay, 0 if unknown; only
+// Decorrelated uses it.
+type Policy interface {
+	Delay(attempt int, prev time.Duration) time.Duration
+}
+
+// Fixed waits the same time before every retry.
+type Fixed struct {
+	Interval time.Duration
+}
+
+func (p Fixed) Delay(int, time.Duration) time.Duration { return p.Interval }
+
+// Exponential waits Base * 2^(attempt-1), capped at Max. Jitter (0..1) randomizes the
+// top fraction of the delay: 0 is deterministic, 1 is "full jitter" in [0, d).
+type Exponential struct {
+	Base   time.Duration
+	Max    time.Duration
+	Jitter float64
+	// Rand returns values in [0, 1); nil means math/rand/v2.
+	Rand func() float64
+}
+
+func (p Exponential) Delay(attempt int, _ time.Duration) time.Duration {
+	d := exp(p.Base, p.Max, attempt)
+	if p.Jitter <= 0 {
+		return d
+	}
+	j := min(p.Jitter, 1)
+	return time.Duration(float64(d) * (1 - j + j*random(p.Rand)))
+}
+
+// Decorrelated is the "decorrelated jitter" policy: a random delay in [Base, 3*prev],
+// capped at Max. Stateless callers that cannot remember prev pass 0; the previous delay
+// is then assumed to be the plain exponential one for attempt-1.
+type Decorrelated struct {
+	Base time.Duration
+	Max  time.Duration
+	Rand func() float64
+}
+
+func (p Decorrelated) Delay(attempt int, prev time.Duration) time.Duration {
+	if prev <= 0 {
+		prev = exp(p.Base, p.Max, attempt-1)
+	}
+	hi := 3 * prev
+	if hi <= p.Base {
+		return capAt(p.Base, p.Max)
+	}
+	return capAt(p.Base+time.Duration(float64(hi-p.Base)*random(p.Rand)), p.Max)
+}
+
+func exp(base, maxDelay time.Duration, attempt int) time.Duration {
+	if attempt <= 1 {
+		return capAt(base, maxDelay)
+	}
+	d := base << uint(min(attempt-1, 30))
+	if d <= 0 { // overflow
+		return maxDelay
+	}
+	return capAt(d, maxDelay)
+}
+
+func capAt(d, maxDelay time.Duration) time.Duration {
+	if maxDelay > 0 && d > maxDelay {
+		return maxDelay
+	}
+	return d
+}
+
+func random(f func() float64) float64 {
+	if f != nil {
+		return f()
+	}
+	return rand.Float64()
+}
+
+// Policies picks a policy per event type, falling back to Default.
+type Policies struct {
+	Default Policy
+	ByType  map[string]Policy
+}
+
+func (p Policies) For(eventType string) Policy {
+	if pol, ok := p.ByType[eventType]; ok {
+		return pol
+	}
+	return p.Default
+}
+
+// Parse reads a policy spec:
+//
+//	fixed:interval=5s
+//	exponential:base=500ms,max=60s,jitter=0.5
+//	decorrelated:base=1s,max=30s
+func Parse(spec string) (Policy, error) {
+	kind, params, _ := strings.Cut(strings.TrimSpace(spec), ":")
+	opts := map[string]string{}
+	for _, kv := range strings.Split(params, ",") {
+		kv = strings.TrimSpace(kv)
+		if kv == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			return nil, fmt.Errorf("retry policy %q: bad option %q", spec, kv)
+		}
+		opts[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+
+	dur := func(key string, def time.Duration) (time.Duration, error) {
+		v, ok := opts[key]
+		delete(opts, key)
+		if !ok {
+			return def, nil
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return 0, fmt.Errorf("retry policy %q: bad %s %q", spec, key, v)
+		}
+		return d, nil
+	}
+
+	var (
+		pol Policy
+		err error
+	)
+	switch kind {
+	case "fixed":
+		var p Fixed
+		p.Interval, err = dur("interval", time.Second)
+		pol = p
+	case "exponential":
+		var p Exponential
+		if p.Base, err = dur("base", time.Second); err != nil {
+			break
+		}
+		if p.Max, err = dur("max", time.Minute); err != nil {
+			break
+		}
+		if v, ok := opts["jitter"]; ok {
+			delete(opts, "jitter")
+			p.Jitter, err = strconv.ParseFloat(v, 64)
+			if err != nil || p.Jitter < 0 || p.Jitter > 1 {
+				err = fmt.Errorf("retry policy %q: jitter must be in [0, 1]", spec)
+			}
+		}
+		pol = p
+	case "decorrelated":
+		var p Decorrelated
+		if p.Base, err = dur("base", time.Second); err != nil {
+			break
+		}
+		p.Max, err = dur("max", time.Minute)
+		pol = p
+	default:
+		return nil, fmt.Errorf("retry policy %q: unknown kind %q (want fixed, exponential or decorrelated)", spec, kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for k := range opts {
+		return nil, fmt.Errorf("retry policy %q: unknown option %q", spec, k)
+	}
+	return pol, nil
+}
+
+// ParsePolicies builds Policies from a default spec and per-type overrides separated by ';':
+//
+//	ticket.created=fixed:interval=2s;ticket.worklog_added=decorrelated:base=1s,max=30s
+func ParsePolicies(defaultSpec, byType string) (Policies, error) {
+	def, err := Parse(defaultSpec)
+	if err != nil {
+		return Policies{}, err
+	}
+	out := Policies{Default: def, ByType: map[string]Policy{}}
+	for _, item := range strings.Split(byType, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		eventType, spec, ok := strings.Cut(item, "=")
+		if !ok || strings.TrimSpace(eventType) == "" {
+			return Policies{}, fmt.Errorf("retry overrides: bad item %q (want <event_type>=<policy>)", item)
+		}
+		pol, err := Parse(spec)
+		if err != nil {
+			return Policies{}, err
+		}
+		out.ByType[strings.TrimSpace(eventType)] = pol
+	}
+	return out, nil
+}
+
+// Clock abstracts time for waiting between retries.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// SystemClock is the real clock.
+var SystemClock Clock = systemClock{}
+
+// Sleep waits d on c, returning ctx.Err() if ctx is cancelled first.
+func Sleep(ctx context.Context, c Clock, d time.Duration) error {
+	select {
+	case <-c.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,118 @@
+package retry
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// fakeClock fires After immediately and records the requested delays.
+type fakeClock struct {
+	now    time.Time
+	waited []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.waited = append(c.waited, d)
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+func TestExponentialDoublesAndCaps(t *testing.T) {
+	p := Exponential{Base: time.Second, Max: 5 * time.Second}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		if got := p.Delay(i+1, 0); got != w {
+			t.Fatalf("attempt %d: expected %s, got %s", i+1, w, got)
+		}
+	}
+	if got := p.Delay(200, 0); got != 5*time.Second {
+		t.Fatalf("large attempt must not overflow, got %s", got)
+	}
+}
+
+func TestExponentialJitterStaysInRange(t *testing.T) {
+	p := Exponential{Base: 4 * time.Second, Max: time.Minute, Jitter: 0.5, Rand: func() float64 { return 0 }}
+	if got := p.Delay(1, 0); got != 2*time.Second {
+		t.Fatalf("rand=0: expected 2s, got %s", got)
+	}
+	p.Rand = func() float64 { return 0.999999 }
+	if got := p.Delay(1, 0); got < 3900*time.Millisecond || got > 4*time.Second {
+		t.Fatalf("rand~1: expected ~4s, got %s", got)
+	}
+}
+
+func TestDecorrelatedUsesPreviousDelay(t *testing.T) {
+	p := Decorrelated{Base: time.Second, Max: time.Minute, Rand: func() float64 { return 1 }}
+	if got := p.Delay(2, 4*time.Second); got != 12*time.Second {
+		t.Fatalf("expected 3*prev=12s, got %s", got)
+	}
+	// Stateless caller: prev assumed to be exponential(attempt-1) = 2s.
+	if got := p.Delay(3, 0); got != 6*time.Second {
+		t.Fatalf("expected 6s, got %s", got)
+	}
+	p.Rand = func() float64 { return 0 }
+	if got := p.Delay(5, 10*time.Second); got != time.Second {
+		t.Fatalf("expected base, got %s", got)
+	}
+	p.Rand = func() float64 { return 1 }
+	if got := p.Delay(5, time.Hour); got != time.Minute {
+		t.Fatalf("expected cap, got %s", got)
+	}
+}
+
+func TestParsePolicies(t *testing.T) {
+	ps, err := ParsePolicies("exponential:base=200ms,max=30s,jitter=0.5", "ticket.created=fixed:interval=2s; ticket.worklog_added=decorrelated:base=1s")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if e, ok := ps.For("other").(Exponential); !ok || e.Base != 200*time.Millisecond || e.Max != 30*time.Second || e.Jitter != 0.5 {
+		t.Fatalf("unexpected default: %#v", ps.For("other"))
+	}
+	if f, ok := ps.For("ticket.created").(Fixed); !ok || f.Interval != 2*time.Second {
+		t.Fatalf("unexpected override: %#v", ps.For("ticket.created"))
+	}
+	if d, ok := ps.For("ticket.worklog_added").(Decorrelated); !ok || d.Max != time.Minute {
+		t.Fatalf("unexpected override: %#v", ps.For("ticket.worklog_added"))
+	}
+
+	for _, bad := range []string{"linear", "fixed:interval=soon", "exponential:jitter=2", "fixed:every=1s"} {
+		if _, err := Parse(bad); err == nil {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestSleepWaitsOnClock(t *testing.T) {
+	c := &fakeClock{now: time.Unix(0, 0)}
+	p := Exponential{Base: 100 * time.Millisecond, Max: time.Second}
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		if err := Sleep(context.Background(), c, p.Delay(attempt, 0)); err != nil {
+			t.Fatalf("sleep: %v", err)
+		}
+	}
+	if len(c.waited) != 3 || c.waited[2] != 400*time.Millisecond {
+		t.Fatalf("unexpected waits: %v", c.waited)
+	}
+	if got := c.now.Sub(time.Unix(0, 0)); got != 700*time.Millisecond {
+		t.Fatalf("expected clock to advance 700ms, got %s", got)
+	}
+}
+
+func TestSleepReturnsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := Sleep(ctx, blockingClock{}, time.Hour); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+type blockingClock struct{}
+
+func (blockingClock) Now() time.Time                       { return time.Time{} }
+func (blockingClock) After(time.Duration) <-chan time.Time { return nil }
@@ -0,0 +1,18 @@
+// Package tracecontext carries the W3C traceparent of the current request.
+package tracecontext
+
+import "context"
+
+type ctxKey struct{}
+
+func With(ctx context.Context, traceparent string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, traceparent)
+}
+
+func Get(ctx context.Context) string {
+	v := ctx.Value(ctxKey{})
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return ""
+}
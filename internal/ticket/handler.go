@@ -23,23 +23,9 @@ func (h *Handler) CreateTicket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
-
 	var req CreateTicketRequest
-	dec := json.NewDecoder(r.Body)
-	dec.DisallowUnknownFields()
-
-	if err := dec.Decode(&req); err != nil {
-		msg := "invalid json"
-		if errors.Is(err, io.EOF) {
-			msg = "empty body"
-		}
-		WriteErrorR(w, r, http.StatusBadRequest, "validation_error", msg)
-		return
-	}
-
-	if err := dec.Decode(&struct{}{}); err != io.EOF {
-		WriteErrorR(w, r, http.StatusBadRequest, "validation_error", "invalid json")
+	if err := decodeJSON(w, r, &req); err != nil {
+		WriteErrorR(w, r, http.StatusBadRequest, "validation_error", err.Error())
 		return
 	}
 
@@ -49,11 +35,12 @@ func (h *Handler) CreateTicket(w http.ResponseWriter, r *http.Request) {
 	}
 
 	t := Ticket{
-		Title:       strings.TrimSpace(req.Title),
-		Description: strings.TrimSpace(req.Description),
-		Status:      "open",
-		CreatedAt:   time.Now().UTC(),
-		UpdatedAt:   time.Now().UTC(),
+		Title:        strings.TrimSpace(req.Title),
+		Description:  strings.TrimSpace(req.Description),
+		Status:       "open",
+		RequesterOrg: strings.TrimSpace(req.RequesterOrg),
+		CreatedAt:    time.Now().UTC(),
+		UpdatedAt:    time.Now().UTC(),
 	}
 
 	t.ID = uuid.NewString()
@@ -93,6 +80,27 @@ func (h *Handler) GetTicket(w http.ResponseWriter, r *http.Request, id string) {
 	writeJSON(w, http.StatusOK, t)
 }
 
+// decodeJSON reads a single strict JSON object from the request body.
+// Returned errors are ValidationError and safe to show to the client.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		if errors.Is(err, io.EOF) {
+			return ValidationError("empty body")
+		}
+		return ValidationError("invalid json")
+	}
+
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		return ValidationError("invalid json")
+	}
+	return nil
+}
+
 func writeJSON(w http.ResponseWriter, status int, v any) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.WriteHeader(status)
@@ -0,0 +1,151 @@
+package ticket
+
+import (
+	"encoding/csv"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (h *Handler) Worklogs(w http.ResponseWriter, r *http.Request, ticketID string) {
+	switch r.Method {
+	case http.MethodGet:
+		h.listWorklogs(w, r, ticketID)
+	case http.MethodPost:
+		h.addWorklog(w, r, ticketID)
+	default:
+		WriteErrorR(w, r, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+	}
+}
+
+func (h *Handler) addWorklog(w http.ResponseWriter, r *http.Request, ticketID string) {
+	var req CreateWorklogRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		WriteErrorR(w, r, http.StatusBadRequest, "validation_error", err.Error())
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		WriteErrorR(w, r, http.StatusBadRequest, "validation_error", err.Error())
+		return
+	}
+
+	created, err := h.Store.AddWorklog(r.Context(), Worklog{
+		TicketID:        ticketID,
+		Agent:           strings.TrimSpace(req.Agent),
+		DurationMinutes: req.DurationMinutes,
+		Billable:        req.Billable,
+		Note:            strings.TrimSpace(req.Note),
+		CreatedAt:       time.Now().UTC(),
+	})
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			WriteErrorR(w, r, http.StatusNotFound, "not_found", "not found")
+			return
+		}
+		h.Log.Error("worklog_create_failed", slog.String("ticket_id", ticketID), slog.String("err", err.Error()))
+		WriteErrorR(w, r, http.StatusInternalServerError, "internal_error", "internal error")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (h *Handler) listWorklogs(w http.ResponseWriter, r *http.Request, ticketID string) {
+	items, err := h.Store.ListWorklogs(r.Context(), ticketID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			WriteErrorR(w, r, http.StatusNotFound, "not_found", "not found")
+			return
+		}
+		h.Log.Error("worklog_list_failed", slog.String("ticket_id", ticketID), slog.String("err", err.Error()))
+		WriteErrorR(w, r, http.StatusInternalServerError, "internal_error", "internal error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Items []Worklog `json:"items"`
+	}{Items: items})
+}
+
+// TimeReport serves GET /reports/time?from=YYYY-MM&to=YYYY-MM[&format=csv].
+// Both bounds are inclusive months in UTC; defaults to the current month.
+func (h *Handler) TimeReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteErrorR(w, r, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
+	}
+
+	q := r.URL.Query()
+	now := time.Now().UTC()
+	thisMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	from, err := parseMonth(q.Get("from"), thisMonth)
+	if err != nil {
+		WriteErrorR(w, r, http.StatusBadRequest, "validation_error", "from must be YYYY-MM")
+		return
+	}
+	to, err := parseMonth(q.Get("to"), from)
+	if err != nil {
+		WriteErrorR(w, r, http.StatusBadRequest, "validation_error", "to must be YYYY-MM")
+		return
+	}
+	if to.Before(from) {
+		WriteErrorR(w, r, http.StatusBadRequest, "validation_error", "to must not be before from")
+		return
+	}
+
+	format := strings.ToLower(strings.TrimSpace(q.Get("format")))
+	if format != "" && format != "json" && format != "csv" {
+		WriteErrorR(w, r, http.StatusBadRequest, "validation_error", "format must be json or csv")
+		return
+	}
+
+	rows, err := h.Store.TimeReport(r.Context(), from, to.AddDate(0, 1, 0))
+	if err != nil {
+		h.Log.Error("time_report_failed", slog.String("err", err.Error()))
+		WriteErrorR(w, r, http.StatusInternalServerError, "internal_error", "internal error")
+		return
+	}
+
+	if format == "csv" {
+		writeTimeReportCSV(w, rows)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		From  string          `json:"from"`
+		To    string          `json:"to"`
+		Items []TimeReportRow `json:"items"`
+	}{From: from.Format("2006-01"), To: to.Format("2006-01"), Items: rows})
+}
+
+func parseMonth(v string, def time.Time) (time.Time, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return def, nil
+	}
+	return time.ParseInLocation("2006-01", v, time.UTC)
+}
+
+func writeTimeReportCSV(w http.ResponseWriter, rows []TimeReportRow) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="time-report.csv"`)
+	w.WriteHeader(http.StatusOK)
+
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"month", "requester_org", "worklogs", "total_minutes", "billable_minutes"})
+	for _, row := range rows {
+		_ = cw.Write([]string{
+			row.Month,
+			row.RequesterOrg,
+			strconv.Itoa(row.Worklogs),
+			strconv.Itoa(row.TotalMinutes),
+			strconv.Itoa(row.BillableMinutes),
+		})
+	}
+	cw.Flush()
+}
@@ -0,0 +1,158 @@
+package ticket_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/k1networth/servicedesk-lite/internal/ticket"
+)
+
+func createTicket(t *testing.T, baseURL, body string) ticket.Ticket {
+	t.Helper()
+
+	resp, err := http.Post(baseURL+"/tickets", "application/json", bytes.NewReader([]byte(body)))
+	if err != nil {
+		t.Fatalf("create ticket: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusCreated {
+		b, _ := io.ReadAll(resp.Body)
+		t.Fatalf("expected %d, got %d, body=%s", http.StatusCreated, resp.StatusCode, string(b))
+	}
+
+	var created ticket.Ticket
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatalf("decode create response: %v", err)
+	}
+	return created
+}
+
+func addWorklog(t *testing.T, baseURL, ticketID, body string) *http.Response {
+	t.Helper()
+
+	resp, err := http.Post(baseURL+"/tickets/"+ticketID+"/worklogs", "application/json", bytes.NewReader([]byte(body)))
+	if err != nil {
+		t.Fatalf("add worklog: %v", err)
+	}
+	return resp
+}
+
+func TestWorklogsTotalsOnTicket(t *testing.T) {
+	srv := newTestServer()
+	t.Cleanup(srv.Close)
+
+	created := createTicket(t, srv.URL, `{"title":"VPN broken","requester_org":"acme"}`)
+
+	for _, body := range []string{
+		`{"agent":"alice","duration_minutes":30,"billable":true,"note":"diagnostics"}`,
+		`{"agent":"bob","duration_minutes":15}`,
+	} {
+		resp := addWorklog(t, srv.URL, created.ID, body)
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("expected %d, got %d", http.StatusCreated, resp.StatusCode)
+		}
+	}
+
+	listResp, err := http.Get(srv.URL + "/tickets/" + created.ID + "/worklogs")
+	if err != nil {
+		t.Fatalf("list worklogs: %v", err)
+	}
+	defer func() { _ = listResp.Body.Close() }()
+
+	var list struct {
+		Items []ticket.Worklog `json:"items"`
+	}
+	if err := json.NewDecoder(listResp.Body).Decode(&list); err != nil {
+		t.Fatalf("decode list response: %v", err)
+	}
+	if len(list.Items) != 2 {
+		t.Fatalf("expected 2 worklogs, got %d", len(list.Items))
+	}
+
+	getResp, err := http.Get(srv.URL + "/tickets/" + created.ID)
+	if err != nil {
+		t.Fatalf("get request: %v", err)
+	}
+	defer func() { _ = getResp.Body.Close() }()
+
+	var got ticket.Ticket
+	if err := json.NewDecoder(getResp.Body).Decode(&got); err != nil {
+		t.Fatalf("decode get response: %v", err)
+	}
+	if got.TimeSpentMinutes != 45 {
+		t.Fatalf("expected time_spent_minutes %d, got %d", 45, got.TimeSpentMinutes)
+	}
+	if got.BillableMinutes != 30 {
+		t.Fatalf("expected billable_minutes %d, got %d", 30, got.BillableMinutes)
+	}
+}
+
+func TestWorklogValidation400(t *testing.T) {
+	srv := newTestServer()
+	t.Cleanup(srv.Close)
+
+	created := createTicket(t, srv.URL, `{"title":"Printer jam"}`)
+
+	resp := addWorklog(t, srv.URL, created.ID, `{"agent":"alice","duration_minutes":0}`)
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		b, _ := io.ReadAll(resp.Body)
+		t.Fatalf("expected %d, got %d, body=%s", http.StatusBadRequest, resp.StatusCode, string(b))
+	}
+}
+
+func TestWorklogUnknownTicket404(t *testing.T) {
+	srv := newTestServer()
+	t.Cleanup(srv.Close)
+
+	resp := addWorklog(t, srv.URL, "missing", `{"agent":"alice","duration_minutes":10}`)
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusNotFound {
+		b, _ := io.ReadAll(resp.Body)
+		t.Fatalf("expected %d, got %d, body=%s", http.StatusNotFound, resp.StatusCode, string(b))
+	}
+}
+
+func TestTimeReportCSV(t *testing.T) {
+	srv := newTestServer()
+	t.Cleanup(srv.Close)
+
+	created := createTicket(t, srv.URL, `{"title":"Mail quota","requester_org":"acme"}`)
+	resp := addWorklog(t, srv.URL, created.ID, `{"agent":"alice","duration_minutes":20,"billable":true}`)
+	_ = resp.Body.Close()
+
+	month := time.Now().UTC().Format("2006-01")
+	reportResp, err := http.Get(srv.URL + "/reports/time?from=" + month + "&to=" + month + "&format=csv")
+	if err != nil {
+		t.Fatalf("report request: %v", err)
+	}
+	defer func() { _ = reportResp.Body.Close() }()
+
+	if reportResp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(reportResp.Body)
+		t.Fatalf("expected %d, got %d, body=%s", http.StatusOK, reportResp.StatusCode, string(b))
+	}
+
+	records, err := csv.NewReader(reportResp.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("read csv: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected header + 1 row, got %d records", len(records))
+	}
+	want := []string{month, "acme", "1", "20", "20"}
+	for i, v := range want {
+		if records[1][i] != v {
+			t.Fatalf("column %d: expected %q, got %q", i, v, records[1][i])
+		}
+	}
+}
@@ -6,17 +6,23 @@ import (
 )
 
 type Ticket struct {
-	ID          string    `json:"id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description,omitempty"`
-	Status      string    `json:"status"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID           string    `json:"id"`
+	Title        string    `json:"title"`
+	Description  string    `json:"description,omitempty"`
+	Status       string    `json:"status"`
+	RequesterOrg string    `json:"requester_org,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+
+	// Worklog totals (minutes); filled on read.
+	TimeSpentMinutes int `json:"time_spent_minutes"`
+	BillableMinutes  int `json:"billable_minutes"`
 }
 
 type CreateTicketRequest struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
+	Title        string `json:"title"`
+	Description  string `json:"description"`
+	RequesterOrg string `json:"requester_org"`
 }
 
 func (r CreateTicketRequest) Validate() error {
@@ -36,5 +42,57 @@ func (r CreateTicketRequest) Validate() error {
 		return ValidationError("description must be at most 5000 characters")
 	}
 
+	if len(strings.TrimSpace(r.RequesterOrg)) > 200 {
+		return ValidationError("requester_org must be at most 200 characters")
+	}
+
+	return nil
+}
+
+type Worklog struct {
+	ID              int64     `json:"id"`
+	TicketID        string    `json:"ticket_id"`
+	Agent           string    `json:"agent"`
+	DurationMinutes int       `json:"duration_minutes"`
+	Billable        bool      `json:"billable"`
+	Note            string    `json:"note,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+type CreateWorklogRequest struct {
+	Agent           string `json:"agent"`
+	DurationMinutes int    `json:"duration_minutes"`
+	Billable        bool   `json:"billable"`
+	Note            string `json:"note"`
+}
+
+const maxWorklogMinutes = 24 * 60
+
+func (r CreateWorklogRequest) Validate() error {
+	agent := strings.TrimSpace(r.Agent)
+	if agent == "" {
+		return ValidationError("agent is required")
+	}
+	if len(agent) > 200 {
+		return ValidationError("agent must be at most 200 characters")
+	}
+	if r.DurationMinutes <= 0 {
+		return ValidationError("duration_minutes must be positive")
+	}
+	if r.DurationMinutes > maxWorklogMinutes {
+		return ValidationError("duration_minutes must be at most 1440")
+	}
+	if len(strings.TrimSpace(r.Note)) > 5000 {
+		return ValidationError("note must be at most 5000 characters")
+	}
 	return nil
 }
+
+// TimeReportRow is one (month, requester_org) bucket of the time report.
+type TimeReportRow struct {
+	Month           string `json:"month"` // YYYY-MM, UTC
+	RequesterOrg    string `json:"requester_org"`
+	Worklogs        int    `json:"worklogs"`
+	TotalMinutes    int    `json:"total_minutes"`
+	BillableMinutes int    `json:"billable_minutes"`
+}
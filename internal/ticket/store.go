@@ -5,7 +5,9 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
+	"sort"
 	"sync"
+	"time"
 )
 
 var ErrNotFound = errors.New("ticket not found")
@@ -13,16 +15,24 @@ var ErrNotFound = errors.New("ticket not found")
 type Store interface {
 	Create(ctx context.Context, t Ticket) (Ticket, error)
 	Get(ctx context.Context, id string) (Ticket, error)
+
+	AddWorklog(ctx context.Context, w Worklog) (Worklog, error)
+	ListWorklogs(ctx context.Context, ticketID string) ([]Worklog, error)
+	// TimeReport aggregates worklogs created in [from, to) by month and requester organization.
+	TimeReport(ctx context.Context, from, to time.Time) ([]TimeReportRow, error)
 }
 
 type InMemoryStore struct {
-	mu   sync.RWMutex
-	byID map[string]Ticket
+	mu       sync.RWMutex
+	byID     map[string]Ticket
+	worklogs map[string][]Worklog
+	lastWLID int64
 }
 
 func NewInMemoryStore() *InMemoryStore {
 	return &InMemoryStore{
-		byID: make(map[string]Ticket),
+		byID:     make(map[string]Ticket),
+		worklogs: make(map[string][]Worklog),
 	}
 }
 
@@ -50,9 +60,89 @@ func (s *InMemoryStore) Get(ctx context.Context, id string) (Ticket, error) {
 	if !ok {
 		return Ticket{}, ErrNotFound
 	}
+	for _, w := range s.worklogs[id] {
+		t.TimeSpentMinutes += w.DurationMinutes
+		if w.Billable {
+			t.BillableMinutes += w.DurationMinutes
+		}
+	}
 	return t, nil
 }
 
+func (s *InMemoryStore) AddWorklog(ctx context.Context, w Worklog) (Worklog, error) {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.byID[w.TicketID]; !ok {
+		return Worklog{}, ErrNotFound
+	}
+
+	s.lastWLID++
+	w.ID = s.lastWLID
+	if w.CreatedAt.IsZero() {
+		w.CreatedAt = time.Now().UTC()
+	}
+	s.worklogs[w.TicketID] = append(s.worklogs[w.TicketID], w)
+	return w, nil
+}
+
+func (s *InMemoryStore) ListWorklogs(ctx context.Context, ticketID string) ([]Worklog, error) {
+	_ = ctx
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.byID[ticketID]; !ok {
+		return nil, ErrNotFound
+	}
+	out := make([]Worklog, len(s.worklogs[ticketID]))
+	copy(out, s.worklogs[ticketID])
+	return out, nil
+}
+
+func (s *InMemoryStore) TimeReport(ctx context.Context, from, to time.Time) ([]TimeReportRow, error) {
+	_ = ctx
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	type key struct{ month, org string }
+	buckets := make(map[key]*TimeReportRow)
+	for ticketID, wls := range s.worklogs {
+		org := s.byID[ticketID].RequesterOrg
+		for _, w := range wls {
+			if w.CreatedAt.Before(from) || !w.CreatedAt.Before(to) {
+				continue
+			}
+			k := key{month: w.CreatedAt.UTC().Format("2006-01"), org: org}
+			row, ok := buckets[k]
+			if !ok {
+				row = &TimeReportRow{Month: k.month, RequesterOrg: k.org}
+				buckets[k] = row
+			}
+			row.Worklogs++
+			row.TotalMinutes += w.DurationMinutes
+			if w.Billable {
+				row.BillableMinutes += w.DurationMinutes
+			}
+		}
+	}
+
+	out := make([]TimeReportRow, 0, len(buckets))
+	for _, row := range buckets {
+		out = append(out, *row)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Month != out[j].Month {
+			return out[i].Month < out[j].Month
+		}
+		return out[i].RequesterOrg < out[j].RequesterOrg
+	})
+	return out, nil
+}
+
 func newID() string {
 	var b [16]byte
 	_, err := rand.Read(b[:])
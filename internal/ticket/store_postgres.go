@@ -3,19 +3,25 @@ package ticket
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"errors"
+	"time"
 
+	"github.com/k1networth/servicedesk-lite/internal/outbox"
 	"github.com/k1networth/servicedesk-lite/internal/shared/events"
 	"github.com/k1networth/servicedesk-lite/internal/shared/requestid"
 )
 
 type PostgresStore struct {
-	db *sql.DB
+	db     *sql.DB
+	outbox *outbox.Writer
 }
 
-func NewPostgresStore(db *sql.DB) *PostgresStore {
-	return &PostgresStore{db: db}
+// NewPostgresStore enqueues events through w; nil means outbox.NewWriter().
+func NewPostgresStore(db *sql.DB, w *outbox.Writer) *PostgresStore {
+	if w == nil {
+		w = outbox.NewWriter()
+	}
+	return &PostgresStore{db: db, outbox: w}
 }
 
 func (s *PostgresStore) Create(ctx context.Context, t Ticket) (Ticket, error) {
@@ -26,38 +32,27 @@ func (s *PostgresStore) Create(ctx context.Context, t Ticket) (Ticket, error) {
 	defer func() { _ = tx.Rollback() }()
 
 	const qTicket = `
-INSERT INTO tickets (id, title, description, status, created_at, updated_at)
-VALUES ($1, $2, $3, $4, $5, $6)
-RETURNING id, title, description, status, created_at, updated_at;
+INSERT INTO tickets (id, title, description, status, requester_org, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING id, title, description, status, requester_org, created_at, updated_at;
 `
 	var out Ticket
 	err = tx.QueryRowContext(ctx, qTicket,
-		t.ID, t.Title, t.Description, t.Status, t.CreatedAt, t.UpdatedAt,
-	).Scan(&out.ID, &out.Title, &out.Description, &out.Status, &out.CreatedAt, &out.UpdatedAt)
+		t.ID, t.Title, t.Description, t.Status, t.RequesterOrg, t.CreatedAt, t.UpdatedAt,
+	).Scan(&out.ID, &out.Title, &out.Description, &out.Status, &out.RequesterOrg, &out.CreatedAt, &out.UpdatedAt)
 	if err != nil {
 		return Ticket{}, err
 	}
 
-	payloadObj := map[string]any{
-		"ticket_id":  out.ID,
-		"title":      out.Title,
-		"status":     out.Status,
-		"created_at": out.CreatedAt,
-		"request_id": requestid.Get(ctx),
-	}
-	payload, err := json.Marshal(payloadObj)
-	if err != nil {
-		return Ticket{}, err
+	payload := events.TicketCreated{
+		TicketID:     out.ID,
+		Title:        out.Title,
+		Status:       out.Status,
+		RequesterOrg: out.RequesterOrg,
+		CreatedAt:    out.CreatedAt,
+		RequestID:    requestid.Get(ctx),
 	}
-
-	const qOutbox = `
-INSERT INTO outbox (aggregate, aggregate_id, event_type, payload)
-VALUES ($1, $2, $3, $4::jsonb);
-`
-	_, err = tx.ExecContext(ctx, qOutbox,
-		"ticket", out.ID, events.EventTypeTicketCreated, payload,
-	)
-	if err != nil {
+	if err := s.outbox.Enqueue(ctx, tx, "ticket", out.ID, events.EventTypeTicketCreated, payload); err != nil {
 		return Ticket{}, err
 	}
 
@@ -70,13 +65,18 @@ VALUES ($1, $2, $3, $4::jsonb);
 
 func (s *PostgresStore) Get(ctx context.Context, id string) (Ticket, error) {
 	const q = `
-SELECT id, title, description, status, created_at, updated_at
-FROM tickets
-WHERE id = $1;
+SELECT t.id, t.title, t.description, t.status, t.requester_org, t.created_at, t.updated_at,
+       COALESCE(SUM(w.duration_minutes), 0),
+       COALESCE(SUM(w.duration_minutes) FILTER (WHERE w.billable), 0)
+FROM tickets t
+LEFT JOIN worklogs w ON w.ticket_id = t.id
+WHERE t.id = $1
+GROUP BY t.id;
 `
 	var out Ticket
 	err := s.db.QueryRowContext(ctx, q, id).
-		Scan(&out.ID, &out.Title, &out.Description, &out.Status, &out.CreatedAt, &out.UpdatedAt)
+		Scan(&out.ID, &out.Title, &out.Description, &out.Status, &out.RequesterOrg, &out.CreatedAt, &out.UpdatedAt,
+			&out.TimeSpentMinutes, &out.BillableMinutes)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return Ticket{}, ErrNotFound
@@ -85,3 +85,118 @@ WHERE id = $1;
 	}
 	return out, nil
 }
+
+func (s *PostgresStore) AddWorklog(ctx context.Context, w Worklog) (Worklog, error) {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return Worklog{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// INSERT ... SELECT keeps the "ticket exists" check and the insert in one statement.
+	const qWorklog = `
+INSERT INTO worklogs (ticket_id, agent, duration_minutes, billable, note)
+SELECT t.id, $2, $3, $4, $5
+FROM tickets t
+WHERE t.id = $1
+RETURNING id, ticket_id, agent, duration_minutes, billable, note, created_at;
+`
+	var out Worklog
+	err = tx.QueryRowContext(ctx, qWorklog,
+		w.TicketID, w.Agent, w.DurationMinutes, w.Billable, w.Note,
+	).Scan(&out.ID, &out.TicketID, &out.Agent, &out.DurationMinutes, &out.Billable, &out.Note, &out.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Worklog{}, ErrNotFound
+		}
+		return Worklog{}, err
+	}
+
+	payload := events.TicketWorklogAdded{
+		TicketID:        out.TicketID,
+		WorklogID:       out.ID,
+		Agent:           out.Agent,
+		DurationMinutes: out.DurationMinutes,
+		Billable:        out.Billable,
+		CreatedAt:       out.CreatedAt,
+		RequestID:       requestid.Get(ctx),
+	}
+	if err := s.outbox.Enqueue(ctx, tx, "ticket", out.TicketID, events.EventTypeTicketWorklogAdded, payload); err != nil {
+		return Worklog{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Worklog{}, err
+	}
+
+	return out, nil
+}
+
+func (s *PostgresStore) ListWorklogs(ctx context.Context, ticketID string) ([]Worklog, error) {
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM tickets WHERE id = $1);`, ticketID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	const q = `
+SELECT id, ticket_id, agent, duration_minutes, billable, note, created_at
+FROM worklogs
+WHERE ticket_id = $1
+ORDER BY created_at, id;
+`
+	rows, err := s.db.QueryContext(ctx, q, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	out := []Worklog{}
+	for rows.Next() {
+		var w Worklog
+		if err := rows.Scan(&w.ID, &w.TicketID, &w.Agent, &w.DurationMinutes, &w.Billable, &w.Note, &w.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *PostgresStore) TimeReport(ctx context.Context, from, to time.Time) ([]TimeReportRow, error) {
+	const q = `
+SELECT to_char(date_trunc('month', w.created_at AT TIME ZONE 'UTC'), 'YYYY-MM') AS month,
+       t.requester_org,
+       COUNT(*),
+       SUM(w.duration_minutes),
+       COALESCE(SUM(w.duration_minutes) FILTER (WHERE w.billable), 0)
+FROM worklogs w
+JOIN tickets t ON t.id = w.ticket_id
+WHERE w.created_at >= $1
+  AND w.created_at < $2
+GROUP BY 1, 2
+ORDER BY 1, 2;
+`
+	rows, err := s.db.QueryContext(ctx, q, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	out := []TimeReportRow{}
+	for rows.Next() {
+		var r TimeReportRow
+		if err := rows.Scan(&r.Month, &r.RequesterOrg, &r.Worklogs, &r.TotalMinutes, &r.BillableMinutes); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,2 @@
+ALTER TABLE tickets
+  DROP COLUMN IF EXISTS requester_org;
@@ -0,0 +1,2 @@
+ALTER TABLE tickets
+  ADD COLUMN IF NOT EXISTS requester_org TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS worklogs (
+  id               BIGSERIAL PRIMARY KEY,
+  ticket_id        TEXT NOT NULL REFERENCES tickets (id) ON DELETE CASCADE,
+  agent            TEXT NOT NULL,
+  duration_minutes INT NOT NULL CHECK (duration_minutes > 0),
+  billable         BOOLEAN NOT NULL DEFAULT false,
+  note             TEXT NOT NULL DEFAULT '',
+  created_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS worklogs_ticket_idx
+  ON worklogs (ticket_id, created_at);
+
+-- time report: range scan by month
+CREATE INDEX IF NOT EXISTS worklogs_created_idx
+  ON worklogs (created_at);
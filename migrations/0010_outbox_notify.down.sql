@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS outbox_notify_new_trg ON outbox;
+DROP FUNCTION IF EXISTS outbox_notify_new();
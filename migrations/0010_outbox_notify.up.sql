@@ -0,0 +1,15 @@
+-- Wake outbox-relay right after commit instead of waiting for the next poll.
+-- Statement-level: one NOTIFY per INSERT statement, payload is not used.
+CREATE OR REPLACE FUNCTION outbox_notify_new() RETURNS trigger AS $$
+BEGIN
+  PERFORM pg_notify('outbox_new', '');
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS outbox_notify_new_trg ON outbox;
+
+CREATE TRIGGER outbox_notify_new_trg
+  AFTER INSERT ON outbox
+  FOR EACH STATEMENT
+  EXECUTE FUNCTION outbox_notify_new();
@@ -0,0 +1,5 @@
+-- Strict per-aggregate ordering: ClaimPendingOrdered looks for an earlier unsent
+-- event of the same aggregate; keep that lookup on a small partial index.
+CREATE INDEX IF NOT EXISTS outbox_aggregate_unsent_idx
+  ON outbox (aggregate, aggregate_id, id)
+  WHERE status IN ('pending', 'processing', 'failed');
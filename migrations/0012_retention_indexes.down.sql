@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS processed_events_done_idx;
+DROP INDEX IF EXISTS outbox_sent_at_idx;
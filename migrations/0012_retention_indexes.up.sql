@@ -0,0 +1,8 @@
+-- retention: find old sent/done rows without scanning the whole table
+CREATE INDEX IF NOT EXISTS outbox_sent_at_idx
+  ON outbox (sent_at)
+  WHERE status = 'sent';
+
+CREATE INDEX IF NOT EXISTS processed_events_done_idx
+  ON processed_events (processed_at)
+  WHERE status = 'done';
@@ -0,0 +1,61 @@
+-- Back to a plain table (copies rows; run with writers stopped).
+
+ALTER TABLE outbox RENAME TO outbox_partitioned;
+DROP TRIGGER IF EXISTS outbox_notify_new_trg ON outbox_partitioned;
+ALTER SEQUENCE outbox_id_seq OWNED BY NONE;
+DROP INDEX IF EXISTS
+  outbox_event_id_uq,
+  outbox_status_created_idx,
+  outbox_aggregate_idx,
+  outbox_pending_created_idx,
+  outbox_processing_started_idx,
+  outbox_failed_created_idx,
+  outbox_aggregate_unsent_idx,
+  outbox_sent_at_idx;
+ALTER INDEX IF EXISTS outbox_pkey RENAME TO outbox_partitioned_pkey;
+
+CREATE TABLE outbox (
+  id                    BIGINT PRIMARY KEY DEFAULT nextval('outbox_id_seq'),
+  aggregate             TEXT NOT NULL,
+  aggregate_id          TEXT NOT NULL,
+  event_type            TEXT NOT NULL,
+  payload               JSONB NOT NULL,
+  status                TEXT NOT NULL DEFAULT 'pending',
+  created_at            TIMESTAMPTZ NOT NULL DEFAULT now(),
+  sent_at               TIMESTAMPTZ NULL,
+  processing_started_at TIMESTAMPTZ NULL,
+  attempts              INT NOT NULL DEFAULT 0,
+  next_retry_at         TIMESTAMPTZ NOT NULL DEFAULT now(),
+  last_error            TEXT NULL,
+  updated_at            TIMESTAMPTZ NOT NULL DEFAULT now(),
+  event_id              UUID NOT NULL DEFAULT gen_random_uuid(),
+  failed_at             TIMESTAMPTZ NULL
+);
+
+ALTER SEQUENCE outbox_id_seq OWNED BY outbox.id;
+
+INSERT INTO outbox (
+  id, event_id, aggregate, aggregate_id, event_type, payload, status, created_at, sent_at,
+  processing_started_at, attempts, next_retry_at, last_error, updated_at, failed_at
+)
+SELECT
+  id, event_id, aggregate, aggregate_id, event_type, payload, status, created_at, sent_at,
+  processing_started_at, attempts, next_retry_at, last_error, updated_at, failed_at
+FROM outbox_partitioned;
+
+DROP TABLE outbox_partitioned;
+
+CREATE INDEX outbox_status_created_idx ON outbox (status, created_at);
+CREATE INDEX outbox_aggregate_idx ON outbox (aggregate, aggregate_id);
+CREATE INDEX outbox_pending_ready_idx ON outbox (status, next_retry_at, created_at);
+CREATE UNIQUE INDEX outbox_event_id_uq ON outbox (event_id);
+CREATE INDEX outbox_failed_created_idx ON outbox (status, created_at) WHERE status = 'failed';
+CREATE INDEX outbox_aggregate_unsent_idx
+  ON outbox (aggregate, aggregate_id, id)
+  WHERE status IN ('pending', 'processing', 'failed');
+CREATE INDEX outbox_sent_at_idx ON outbox (sent_at) WHERE status = 'sent';
+
+CREATE TRIGGER outbox_notify_new_trg
+  AFTER INSERT ON outbox
+  FOR EACH STATEMENT
+  EXECUTE FUNCTION outbox_notify_new();
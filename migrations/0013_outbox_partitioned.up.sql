@@ -0,0 +1,104 @@
+-- Move outbox to a table range-partitioned by created_at (daily partitions).
+--
+-- Rows are copied, so run this with ticket-service writes and outbox-relay stopped.
+-- Future partitions are pre-created and old fully-sent ones detached/dropped by
+-- outbox.PartitionManager (runs inside outbox-relay).
+--
+-- Partitioned tables require the partition key in every unique index, so the
+-- primary key becomes (id, created_at) and the event_id index (event_id, created_at).
+
+ALTER TABLE outbox RENAME TO outbox_legacy;
+DROP TRIGGER IF EXISTS outbox_notify_new_trg ON outbox_legacy;
+ALTER INDEX IF EXISTS outbox_pkey RENAME TO outbox_legacy_pkey;
+DROP INDEX IF EXISTS
+  outbox_status_created_idx,
+  outbox_aggregate_idx,
+  outbox_pending_ready_idx,
+  outbox_event_id_uq,
+  outbox_failed_created_idx,
+  outbox_aggregate_unsent_idx,
+  outbox_sent_at_idx;
+ALTER SEQUENCE outbox_id_seq OWNED BY NONE;
+
+CREATE TABLE outbox (
+  id                    BIGINT NOT NULL DEFAULT nextval('outbox_id_seq'),
+  event_id              UUID NOT NULL DEFAULT gen_random_uuid(),
+  aggregate             TEXT NOT NULL,
+  aggregate_id          TEXT NOT NULL,
+  event_type            TEXT NOT NULL,
+  payload               JSONB NOT NULL,
+  status                TEXT NOT NULL DEFAULT 'pending',
+  created_at            TIMESTAMPTZ NOT NULL DEFAULT now(),
+  sent_at               TIMESTAMPTZ NULL,
+  processing_started_at TIMESTAMPTZ NULL,
+  attempts              INT NOT NULL DEFAULT 0,
+  next_retry_at         TIMESTAMPTZ NOT NULL DEFAULT now(),
+  last_error            TEXT NULL,
+  updated_at            TIMESTAMPTZ NOT NULL DEFAULT now(),
+  failed_at             TIMESTAMPTZ NULL,
+  PRIMARY KEY (id, created_at)
+) PARTITION BY RANGE (created_at);
+
+ALTER SEQUENCE outbox_id_seq OWNED BY outbox.id;
+
+-- Indexes on the parent are created on every partition.
+-- Pending rows are tiny partial indexes ordered by created_at, so ClaimPending /
+-- LagSeconds become a merge-append over partitions instead of a full scan.
+CREATE UNIQUE INDEX outbox_event_id_uq ON outbox (event_id, created_at);
+CREATE INDEX outbox_status_created_idx ON outbox (status, created_at);
+CREATE INDEX outbox_aggregate_idx ON outbox (aggregate, aggregate_id);
+CREATE INDEX outbox_pending_created_idx
+  ON outbox (created_at) INCLUDE (next_retry_at)
+  WHERE status = 'pending';
+CREATE INDEX outbox_processing_started_idx
+  ON outbox (processing_started_at)
+  WHERE status = 'processing';
+CREATE INDEX outbox_failed_created_idx
+  ON outbox (status, created_at)
+  WHERE status = 'failed';
+CREATE INDEX outbox_aggregate_unsent_idx
+  ON outbox (aggregate, aggregate_id, id)
+  WHERE status IN ('pending', 'processing', 'failed');
+CREATE INDEX outbox_sent_at_idx
+  ON outbox (sent_at)
+  WHERE status = 'sent';
+
+-- Safety net: inserts never fail if the relay has not pre-created a partition yet.
+-- Should stay empty; PartitionManager cannot create a day that already has rows here.
+CREATE TABLE outbox_default PARTITION OF outbox DEFAULT;
+
+-- Everything before today goes into one range partition; it is dropped like any
+-- other once it is fully sent and older than the retention window.
+DO $$
+DECLARE
+  today TIMESTAMPTZ := date_trunc('day', now() AT TIME ZONE 'UTC') AT TIME ZONE 'UTC';
+  d     TIMESTAMPTZ;
+BEGIN
+  EXECUTE format(
+    'CREATE TABLE %I PARTITION OF outbox FOR VALUES FROM (MINVALUE) TO (%L)',
+    'outbox_p' || to_char(today AT TIME ZONE 'UTC', 'YYYYMMDD') || '_before', today);
+
+  d := today;
+  WHILE d < today + interval '7 days' LOOP
+    EXECUTE format(
+      'CREATE TABLE %I PARTITION OF outbox FOR VALUES FROM (%L) TO (%L)',
+      'outbox_p' || to_char(d AT TIME ZONE 'UTC', 'YYYYMMDD'), d, d + interval '1 day');
+    d := d + interval '1 day';
+  END LOOP;
+END $$;
+
+INSERT INTO outbox (
+  id, event_id, aggregate, aggregate_id, event_type, payload, status, created_at, sent_at,
+  processing_started_at, attempts, next_retry_at, last_error, updated_at, failed_at
+)
+SELECT
+  id, event_id, aggregate, aggregate_id, event_type, payload, status, created_at, sent_at,
+  processing_started_at, attempts, next_retry_at, last_error, updated_at, failed_at
+FROM outbox_legacy;
+
+DROP TABLE outbox_legacy;
+
+CREATE TRIGGER outbox_notify_new_trg
+  AFTER INSERT ON outbox
+  FOR EACH STATEMENT
+  EXECUTE FUNCTION outbox_notify_new();
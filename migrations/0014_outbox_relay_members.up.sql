@@ -0,0 +1,6 @@
+-- outbox-relay sharding: live replicas heartbeat here so each can compute its fair
+-- share of shards. Shard ownership itself is held via session advisory locks.
+CREATE TABLE IF NOT EXISTS outbox_relay_members (
+  member_id    TEXT PRIMARY KEY,
+  heartbeat_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,3 @@
+-- Payload schema version of each outbox event (see internal/shared/events/schemas).
+-- Rows written before this migration carry the v1 payloads.
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS schema_version INT NOT NULL DEFAULT 1;
@@ -0,0 +1,4 @@
+ALTER TABLE outbox
+  DROP COLUMN IF EXISTS traceparent,
+  DROP COLUMN IF EXISTS tenant_id,
+  DROP COLUMN IF EXISTS request_id;
@@ -0,0 +1,6 @@
+-- Event metadata filled by outbox.Writer from the producer's context. Kept out of the
+-- payload so payload schemas stay about the domain data.
+ALTER TABLE outbox
+  ADD COLUMN IF NOT EXISTS request_id  TEXT NULL,
+  ADD COLUMN IF NOT EXISTS tenant_id   TEXT NULL,
+  ADD COLUMN IF NOT EXISTS traceparent TEXT NULL;
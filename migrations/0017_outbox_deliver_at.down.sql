@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS outbox_scheduled_idx;
+DROP INDEX IF EXISTS outbox_pending_ready_idx;
+-- Older code knows no 'cancelled': keep such rows finished so they are never published.
+UPDATE outbox SET status = 'sent', sent_at = COALESCE(sent_at, updated_at) WHERE status = 'cancelled';
+ALTER TABLE outbox DROP COLUMN IF EXISTS deliver_at;
@@ -0,0 +1,15 @@
+-- Scheduled events: deliver_at is set by outbox.Writer (DeliverAt option) and seeds
+-- next_retry_at, so the relay's "next_retry_at <= now()" claim predicate skips them
+-- until they are due. Cancelled scheduled events get status 'cancelled'.
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS deliver_at TIMESTAMPTZ NULL;
+
+-- With many future rows the created_at-ordered pending index would walk past all of
+-- them; this one lets ClaimPending range-scan only the rows that are due.
+CREATE INDEX IF NOT EXISTS outbox_pending_ready_idx
+  ON outbox (next_retry_at, created_at)
+  WHERE status = 'pending';
+
+-- Writer.Cancel looks up pending scheduled events of one aggregate.
+CREATE INDEX IF NOT EXISTS outbox_scheduled_idx
+  ON outbox (aggregate, aggregate_id, event_type)
+  WHERE status = 'pending' AND deliver_at IS NOT NULL;
@@ -0,0 +1,4 @@
+-- Priority lanes: 0 = low (bulk), 1 = normal, 2 = high (see internal/outbox/priority.go).
+-- The claim takes higher priorities first (with aging, Store.MaxPriorityWait), oldest
+-- first within a priority; see 0021_outbox_pending_priority for the per-lane index.
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS priority SMALLINT NOT NULL DEFAULT 1;
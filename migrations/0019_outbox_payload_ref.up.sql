@@ -0,0 +1,3 @@
+-- Claim check: payloads above outbox.Writer's threshold are stored in a blob store; the
+-- row keeps payload = 'null' and a reference {"uri","sha256","size"} here.
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS payload_ref JSONB NULL;
@@ -0,0 +1,14 @@
+-- Audit log of outbox admin actions (outbox.Admin: requeue, skip, purge), written in the
+-- same statement as the change itself, with the ids of the affected rows.
+CREATE TABLE IF NOT EXISTS outbox_admin_audit (
+  id         BIGSERIAL PRIMARY KEY,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  actor      TEXT NOT NULL,
+  action     TEXT NOT NULL,
+  reason     TEXT NULL,
+  filter     JSONB NOT NULL,
+  event_ids  BIGINT[] NOT NULL,
+  affected   BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS outbox_admin_audit_created_idx ON outbox_admin_audit (created_at);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS outbox_pending_priority_idx;
@@ -0,0 +1,7 @@
+-- Store.ClaimPending claims one priority lane at a time: WHERE priority = $n AND
+-- next_retry_at <= now() ORDER BY created_at. The index range-scans only the lane's
+-- due rows, like outbox_pending_ready_idx does for all lanes, so scheduled events that
+-- are not due yet are never read; the due rows are then sorted by created_at.
+CREATE INDEX IF NOT EXISTS outbox_pending_priority_idx
+  ON outbox (priority, next_retry_at, created_at)
+  WHERE status = 'pending';